@@ -3,10 +3,12 @@ package assert
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"math"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -40,10 +42,16 @@ func Bytes(t *testing.T, actual []byte, expected []byte) {
 // Two lists are equal (same length & same values in the same order)
 func List[T comparable](t *testing.T, actuals []T, expecteds []T) {
 	t.Helper()
-	Equal(t, len(actuals), len(expecteds))
+	if len(actuals) != len(expecteds) {
+		t.Errorf("\nexpected: %v\nto equal: %v\nlength: %d != %d", expecteds, actuals, len(expecteds), len(actuals))
+		t.FailNow()
+	}
 
 	for i, actual := range actuals {
-		Equal(t, actual, expecteds[i])
+		if actual != expecteds[i] {
+			t.Errorf("\nexpected: %v\nto equal: %v\nindex %d: '%v' != '%v'", expecteds, actuals, i, expecteds[i], actual)
+			t.FailNow()
+		}
 	}
 }
 
@@ -99,41 +107,151 @@ func StringContains(t *testing.T, actual string, expected string) {
 func Error(t *testing.T, actual error, expected error) {
 	t.Helper()
 	if !errors.Is(actual, expected) {
-		t.Errorf("expected '%s' to be '%s'", actual, expected)
+		t.Errorf("expected '%s' to be '%s'\n%s", actual, expected, errorChain(actual))
 		t.FailNow()
 	}
 }
 
+// The default tolerance of Nowish and Timeish
+var Tolerance = time.Second
+
+// The clocks Nowish compares against (see SetClock), by test name
+var clocks sync.Map
+
+// Makes Nowish, within t and its subtests, compare against now (e.g.
+// a tests.Clock's Now) rather than the real time, until t ends.
+// Other tests, including parallel ones, are unaffected.
+func SetClock(t *testing.T, now func() time.Time) {
+	name := t.Name()
+	previous, existed := clocks.Swap(name, now)
+	t.Cleanup(func() {
+		if existed {
+			clocks.Store(name, previous)
+		} else {
+			clocks.Delete(name)
+		}
+	})
+}
+
+// The clock of the test, or of its closest parent which has one
+func currentTime(t *testing.T) time.Time {
+	name := t.Name()
+	for {
+		if now, ok := clocks.Load(name); ok {
+			return now.(func() time.Time)()
+		}
+		i := strings.LastIndexByte(name, '/')
+		if i == -1 {
+			return time.Now()
+		}
+		name = name[:i]
+	}
+}
+
+// The value is within Tolerance of now. It can be a time.Time,
+// *time.Time, unix seconds or milliseconds (any int/float type or a
+// json.Number, as found in a typed.Typed), an RFC3339 string, or
+// a string in the given format.
 func Nowish(t *testing.T, actual any, format ...string) {
 	t.Helper()
+	NowishWithin(t, actual, Tolerance, format...)
+}
 
-	var d time.Time
+// Like Nowish, with an explicit tolerance
+func NowishWithin(t *testing.T, actual any, tolerance time.Duration, format ...string) {
+	t.Helper()
+	d := toTime(t, "Nowish", actual, format)
+	now := currentTime(t)
+	if diff := absDuration(now.Sub(d)); diff > tolerance {
+		t.Errorf("expected '%s' to be nowish ('%s'), off by %s (tolerance %s)", d.Round(0), now.Round(0), diff, tolerance)
+		t.FailNow()
+	}
+}
 
+// The value (anything Nowish accepts) is within Tolerance (or the
+// given tolerance) of expected
+func Timeish(t *testing.T, actual any, expected time.Time, tolerance ...time.Duration) {
+	t.Helper()
+	within := Tolerance
+	if len(tolerance) == 1 {
+		within = tolerance[0]
+	}
+	d := toTime(t, "Timeish", actual, nil)
+	if diff := absDuration(expected.Sub(d)); diff > within {
+		t.Errorf("expected '%s' to be around '%s', off by %s (tolerance %s)", d.Round(0), expected.Round(0), diff, within)
+		t.FailNow()
+	}
+}
+
+func toTime(t *testing.T, caller string, actual any, format []string) time.Time {
+	t.Helper()
 	if len(format) == 1 {
-		var err error
-		d, err = time.Parse(format[0], actual.(string))
+		s, ok := actual.(string)
+		if !ok {
+			t.Fatalf("assert.%s: a format requires a string, got %T", caller, actual)
+		}
+		d, err := time.Parse(format[0], s)
 		if err != nil {
-			t.Errorf("date is not a valid format: %s", actual.(string))
+			t.Errorf("date is not a valid format: %s", s)
 			t.FailNow()
 		}
-	} else {
-		d = actual.(time.Time)
+		return d
 	}
 
-	diff := math.Abs(time.Now().UTC().Sub(d).Seconds())
-	if diff > 1 {
-		t.Errorf("expected '%s' to be nowish", d)
-		t.FailNow()
+	switch v := actual.(type) {
+	case time.Time:
+		return v
+	case *time.Time:
+		if v == nil {
+			t.Errorf("expected a time, got nil")
+			t.FailNow()
+			return time.Time{}
+		}
+		return *v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			t.Fatalf("assert.%s: invalid number: %s", caller, v)
+		}
+		return fromUnix(f)
+	case string:
+		d, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			t.Errorf("date is not a valid RFC3339 time: %s", v)
+			t.FailNow()
+		}
+		return d
+	}
+
+	rv := reflect.ValueOf(actual)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fromUnix(float64(rv.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fromUnix(float64(rv.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return fromUnix(rv.Float())
+	}
+
+	t.Fatalf("assert.%s: unsupported time value %T (%v)", caller, actual, actual)
+	return time.Time{}
+}
+
+// Unix seconds or, for values past the year 33658 in seconds,
+// milliseconds
+func fromUnix(n float64) time.Time {
+	if math.Abs(n) >= 1e12 {
+		return time.UnixMilli(int64(n))
 	}
+	sec, frac := math.Modf(n)
+	return time.Unix(int64(sec), int64(frac*1e9))
 }
 
-func Timeish(t *testing.T, actual time.Time, expected time.Time) {
-	t.Helper()
-	diff := math.Abs(expected.Sub(actual).Seconds())
-	if diff > 1 {
-		t.Errorf("expected '%s' to be around '%s'", actual, expected)
-		t.FailNow()
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
 	}
+	return d
 }
 
 func Fail(t *testing.T, fmt string, args ...interface{}) {
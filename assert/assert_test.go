@@ -0,0 +1,30 @@
+package assert
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Nowish_Clock(t *testing.T) {
+	frozen := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	t.Run("frozen", func(t *testing.T) {
+		t.Parallel()
+		SetClock(t, func() time.Time { return frozen })
+		for i := 0; i < 100; i++ {
+			Nowish(t, frozen)
+			time.Sleep(time.Millisecond)
+		}
+		t.Run("inherited", func(t *testing.T) {
+			Nowish(t, frozen)
+		})
+	})
+
+	t.Run("real", func(t *testing.T) {
+		t.Parallel()
+		for i := 0; i < 100; i++ {
+			Nowish(t, time.Now())
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
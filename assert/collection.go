@@ -0,0 +1,198 @@
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The list contains the value
+func Contains[T comparable](t *testing.T, list []T, value T) {
+	t.Helper()
+	for _, v := range list {
+		if v == value {
+			return
+		}
+	}
+	t.Errorf("\nexpected: %v\nto contain: '%v'", list, value)
+	t.FailNow()
+}
+
+// The list doesn't contain the value
+func NotContains[T comparable](t *testing.T, list []T, value T) {
+	t.Helper()
+	for i, v := range list {
+		if v == value {
+			t.Errorf("\nexpected: %v\nto not contain: '%v' (found at index %d)", list, value, i)
+			t.FailNow()
+		}
+	}
+}
+
+// The list contains every expected value (in any order, with
+// duplicates needing to appear as many times)
+func ContainsAll[T comparable](t *testing.T, list []T, expected ...T) {
+	t.Helper()
+	missing, _ := diffElements(list, expected)
+	if len(missing) > 0 {
+		t.Errorf("\nexpected: %v\nto contain: %v\nmissing: %v", list, expected, missing)
+		t.FailNow()
+	}
+}
+
+// Both lists have the same values, in any order
+func ElementsMatch[T comparable](t *testing.T, actual []T, expected []T) {
+	t.Helper()
+	missing, unexpected := diffElements(actual, expected)
+	if len(missing) > 0 || len(unexpected) > 0 {
+		t.Errorf("\nexpected: %v\nto match: %v%s", expected, actual, elementDiff(missing, unexpected))
+		t.FailNow()
+	}
+}
+
+// The length of a slice, array, map, string or channel
+func Len(t *testing.T, value any, expected int) {
+	t.Helper()
+	l, ok := length(value)
+	if !ok {
+		t.Fatalf("assert.Len: %T has no length", value)
+	}
+	if l != expected {
+		t.Errorf("\nexpected length: %d\n\t got: %d\nvalue: %v", expected, l, value)
+		t.FailNow()
+	}
+}
+
+// A nil or 0-length slice, array, map, string or channel
+func Empty(t *testing.T, value any) {
+	t.Helper()
+	if value == nil {
+		return
+	}
+	l, ok := length(value)
+	if !ok {
+		t.Fatalf("assert.Empty: %T has no length", value)
+	}
+	if l != 0 {
+		t.Errorf("expected empty, got %d item(s): %v", l, value)
+		t.FailNow()
+	}
+}
+
+// A slice, array, map, string or channel with at least 1 item
+func NotEmpty(t *testing.T, value any) {
+	t.Helper()
+	if value == nil {
+		t.Error("expected not empty, got nil")
+		t.FailNow()
+	}
+	l, ok := length(value)
+	if !ok {
+		t.Fatalf("assert.NotEmpty: %T has no length", value)
+	}
+	if l == 0 {
+		t.Errorf("expected not empty, got: %v", value)
+		t.FailNow()
+	}
+}
+
+// Both maps have the same keys and values
+func MapEqual[K comparable, V comparable](t *testing.T, actual map[K]V, expected map[K]V) {
+	t.Helper()
+	diff := diffMaps(actual, expected, true)
+	if diff != "" {
+		t.Errorf("\nexpected: %v\nto equal: %v%s", expected, actual, diff)
+		t.FailNow()
+	}
+}
+
+// The map has every expected key with its expected value (other
+// keys are ignored)
+func MapContains[K comparable, V comparable](t *testing.T, actual map[K]V, expected map[K]V) {
+	t.Helper()
+	diff := diffMaps(actual, expected, false)
+	if diff != "" {
+		t.Errorf("\nexpected: %v\nto contain: %v%s", actual, expected, diff)
+		t.FailNow()
+	}
+}
+
+// the expected values not in actual, and the actual values not in
+// expected (as a multiset: duplicates count)
+func diffElements[T comparable](actual []T, expected []T) ([]T, []T) {
+	counts := make(map[T]int, len(actual))
+	for _, v := range actual {
+		counts[v]++
+	}
+
+	var missing []T
+	for _, v := range expected {
+		if counts[v] == 0 {
+			missing = append(missing, v)
+			continue
+		}
+		counts[v]--
+	}
+
+	var unexpected []T
+	for _, v := range actual {
+		if counts[v] > 0 {
+			unexpected = append(unexpected, v)
+			counts[v]--
+		}
+	}
+	return missing, unexpected
+}
+
+func elementDiff[T any](missing []T, unexpected []T) string {
+	diff := ""
+	if len(missing) > 0 {
+		diff += fmt.Sprintf("\nmissing: %v", missing)
+	}
+	if len(unexpected) > 0 {
+		diff += fmt.Sprintf("\nunexpected: %v", unexpected)
+	}
+	return diff
+}
+
+func diffMaps[K comparable, V comparable](actual map[K]V, expected map[K]V, exact bool) string {
+	var lines []string
+	for k, e := range expected {
+		a, exists := actual[k]
+		if !exists {
+			lines = append(lines, fmt.Sprintf("  - %v: %v", k, e))
+		} else if a != e {
+			lines = append(lines, fmt.Sprintf("  ~ %v: expected '%v', got '%v'", k, e, a))
+		}
+	}
+	if exact {
+		for k, a := range actual {
+			if _, exists := expected[k]; !exists {
+				lines = append(lines, fmt.Sprintf("  + %v: %v", k, a))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	// by key, ignoring the -/+/~ marker
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i][4:] < lines[j][4:]
+	})
+	return "\n(- missing, + unexpected, ~ different)\n" + strings.Join(lines, "\n")
+}
+
+func length(value any) (int, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.String, reflect.Chan:
+		return v.Len(), true
+	case reflect.Ptr:
+		if !v.IsNil() && v.Elem().Kind() == reflect.Array {
+			return v.Elem().Len(), true
+		}
+	}
+	return 0, false
+}
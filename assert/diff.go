@@ -0,0 +1,161 @@
+package assert
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Colorize failure output when stdout is a terminal (and NO_COLOR
+// isn't set)
+var Color = useColor()
+
+func useColor() bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	stat, err := os.Stdout.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+func red(s string) string {
+	return colorize("31", s)
+}
+
+func green(s string) string {
+	return colorize("32", s)
+}
+
+func cyan(s string) string {
+	return colorize("36", s)
+}
+
+func colorize(code string, s string) string {
+	if !Color || s == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// lines of context around each change
+const diffContext = 3
+
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// A unified diff (- expected, + actual)
+func unifiedDiff(expected string, actual string) string {
+	lines := diffLines(strings.Split(expected, "\n"), strings.Split(actual, "\n"))
+
+	var sb strings.Builder
+	sb.WriteString(red("--- expected") + "\n" + green("+++ actual") + "\n")
+
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+
+		// a hunk: from diffContext lines before the change until
+		// there are more than 2*diffContext unchanged lines
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].op == ' ' {
+				run++
+			}
+			if run == len(lines) || run-end > 2*diffContext {
+				end += min(run-end, diffContext)
+				break
+			}
+			end = run
+		}
+
+		oldStart, newStart := 1, 1
+		for _, l := range lines[:start] {
+			if l.op != '+' {
+				oldStart++
+			}
+			if l.op != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, l := range lines[start:end] {
+			if l.op != '+' {
+				oldCount++
+			}
+			if l.op != '-' {
+				newCount++
+			}
+		}
+
+		sb.WriteString(cyan(fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, oldCount, newStart, newCount)))
+		sb.WriteByte('\n')
+		for _, l := range lines[start:end] {
+			line := string(l.op) + l.text
+			switch l.op {
+			case '-':
+				line = red(line)
+			case '+':
+				line = green(line)
+			}
+			sb.WriteString(line)
+			sb.WriteByte('\n')
+		}
+		i = end
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// line diff based on the longest common subsequence
+func diffLines(a []string, b []string) []diffLine {
+	// lcs[i][j] = length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{'+', b[j]})
+	}
+	return lines
+}
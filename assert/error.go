@@ -0,0 +1,117 @@
+package assert
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// err is nil
+func NoError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Errorf("unexpected error: %s\n%s", err, errorChain(err))
+		t.FailNow()
+	}
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Something in err's chain is a T (per errors.As), which is returned
+func ErrorAs[T any](t *testing.T, err error) T {
+	t.Helper()
+	var target T
+	// errors.As panics for these. Usually T is a value type whose
+	// Error method has a pointer receiver, and *T was meant.
+	// (reflect.TypeFor needs go 1.22)
+	targetType := reflect.TypeOf((*T)(nil)).Elem()
+	if targetType.Kind() != reflect.Interface && !targetType.Implements(errorType) {
+		if reflect.PointerTo(targetType).Implements(errorType) {
+			t.Fatalf("assert.ErrorAs: %s doesn't implement error, but *%s does, use ErrorAs[*%s]", targetType, targetType, targetType)
+		}
+		t.Fatalf("assert.ErrorAs: %s isn't an error or an interface", targetType)
+	}
+	if err == nil {
+		t.Errorf("expected a %s error, got nil", targetType)
+		t.FailNow()
+		return target
+	}
+	if !errors.As(err, &target) {
+		t.Errorf("expected a %s error in:\n%s", targetType, errorChain(err))
+		t.FailNow()
+	}
+	return target
+}
+
+// err's message contains the substring
+func ErrorContains(t *testing.T, err error, expected string) {
+	t.Helper()
+	if err == nil {
+		t.Errorf("expected an error containing '%s', got nil", expected)
+		t.FailNow()
+		return
+	}
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("\nexpected error: '%s'\nto contain: '%s'\n%s", err, expected, errorChain(err))
+		t.FailNow()
+	}
+}
+
+// err's message matches the regular expression
+func ErrorMatches(t *testing.T, err error, pattern string) {
+	t.Helper()
+	re, compileErr := regexp.Compile(pattern)
+	if compileErr != nil {
+		t.Fatalf("assert.ErrorMatches: invalid pattern %q: %s", pattern, compileErr)
+	}
+	if err == nil {
+		t.Errorf("expected an error matching '%s', got nil", pattern)
+		t.FailNow()
+		return
+	}
+	if !re.MatchString(err.Error()) {
+		t.Errorf("\nexpected error: '%s'\nto match: '%s'\n%s", err, pattern, errorChain(err))
+		t.FailNow()
+	}
+}
+
+// The unwrap chain, one error per line, with each errors.Join (or
+// any Unwrap() []error) branch starting with a "-":
+//
+//	chain:
+//	  *fmt.wrapError: load: a\nopen x: file does not exist
+//	  *errors.joinError: a\nopen x: file does not exist
+//	    - *errors.errorString: a
+//	    - *fs.PathError: open x: file does not exist
+//	      *errors.errorString: file does not exist
+func errorChain(err error) string {
+	var sb strings.Builder
+	sb.WriteString("chain:\n")
+	writeChain(&sb, err, "  ", "  ")
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// first is the prefix of the first line, indent of the ones after it
+func writeChain(sb *strings.Builder, err error, first string, indent string) {
+	prefix := first
+	for err != nil {
+		sb.WriteString(prefix)
+		sb.WriteString(fmt.Sprintf("%T: %s\n", err, strings.ReplaceAll(err.Error(), "\n", "\\n")))
+		prefix = indent
+
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				writeChain(sb, inner, indent+"  - ", indent+"    ")
+			}
+			return
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package assert
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+type codeError struct {
+	code int
+}
+
+func (e *codeError) Error() string {
+	return fmt.Sprintf("code %d", e.code)
+}
+
+func Test_ErrorAs(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &codeError{code: 9})
+	Equal(t, ErrorAs[*codeError](t, err).code, 9)
+
+	// interfaces work too
+	Equal(t, ErrorAs[interface{ Error() string }](t, err).Error(), "wrapped: code 9")
+
+	joined := errors.Join(errors.New("a"), &fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist})
+	Equal(t, ErrorAs[*fs.PathError](t, joined).Path, "x")
+}
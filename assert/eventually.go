@@ -0,0 +1,79 @@
+package assert
+
+import (
+	"testing"
+	"time"
+)
+
+// How often EventuallyEqual and Never poll when no interval is given
+var PollInterval = 10 * time.Millisecond
+
+// cond returns true within timeout (checked every interval)
+func Eventually(t *testing.T, cond func() bool, timeout time.Duration, interval time.Duration) {
+	t.Helper()
+	if !Poll(timeout, interval, cond) {
+		t.Errorf("condition not met within %s", timeout)
+		t.FailNow()
+	}
+}
+
+// fn returns expected within timeout. On failure, the last value
+// fn returned is reported.
+func EventuallyEqual[T comparable](t *testing.T, fn func() T, expected T, timeout time.Duration, interval ...time.Duration) {
+	t.Helper()
+	var last T
+	equal := Poll(timeout, pollInterval(interval), func() bool {
+		last = fn()
+		return last == expected
+	})
+	if !equal {
+		t.Errorf("\nexpected: '%v'\nwithin %s, last got: '%v'", expected, timeout, last)
+		t.FailNow()
+	}
+}
+
+// cond stays false for the whole duration
+func Never(t *testing.T, cond func() bool, duration time.Duration, interval ...time.Duration) {
+	t.Helper()
+	start := time.Now()
+	every := pollInterval(interval)
+	for {
+		if cond() {
+			t.Errorf("condition was met after %s, expected it never to be within %s", time.Since(start).Round(time.Millisecond), duration)
+			t.FailNow()
+		}
+		if time.Since(start) >= duration {
+			return
+		}
+		time.Sleep(every)
+	}
+}
+
+// Calls cond every interval until it returns true (true) or timeout
+// passes (false). cond is checked at least once, and once more right
+// at the timeout. For Eventually-like helpers with their own failure
+// message, e.g. tests.EventuallyRow.
+func Poll(timeout time.Duration, interval time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if cond() {
+			return true
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+		if remaining < interval {
+			time.Sleep(remaining)
+		} else {
+			time.Sleep(interval)
+		}
+	}
+}
+
+func pollInterval(interval []time.Duration) time.Duration {
+	if len(interval) == 1 {
+		return interval[0]
+	}
+	return PollInterval
+}
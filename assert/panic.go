@@ -0,0 +1,80 @@
+package assert
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+// fn panics
+func Panics(t *testing.T, fn func()) {
+	t.Helper()
+	if panicked, _, _ := catch(fn); !panicked {
+		t.Error("expected a panic, got none")
+		t.FailNow()
+	}
+}
+
+// fn panics with the expected value. A string expectation only has
+// to be contained in the panic's message (or error), an error
+// expectation is matched with errors.Is, anything else needs to be
+// deeply equal to the recovered value.
+func PanicsWith(t *testing.T, fn func(), expected any) {
+	t.Helper()
+	panicked, recovered, stack := catch(fn)
+	if !panicked {
+		t.Errorf("expected a panic with '%v', got none", expected)
+		t.FailNow()
+		return
+	}
+
+	var matched bool
+	switch e := expected.(type) {
+	case string:
+		matched = strings.Contains(panicMessage(recovered), e)
+	case error:
+		err, ok := recovered.(error)
+		matched = ok && errors.Is(err, e)
+	default:
+		matched = reflect.DeepEqual(recovered, expected)
+	}
+
+	if !matched {
+		t.Errorf("\nexpected panic: '%v'\n\t got: '%v'\n\n%s", expected, recovered, stack)
+		t.FailNow()
+	}
+}
+
+// fn doesn't panic
+func NotPanics(t *testing.T, fn func()) {
+	t.Helper()
+	if panicked, recovered, stack := catch(fn); panicked {
+		t.Errorf("unexpected panic: '%v'\n\n%s", recovered, stack)
+		t.FailNow()
+	}
+}
+
+// panicked is tracked separately from the recovered value, which
+// can legitimately be nil
+func catch(fn func()) (panicked bool, recovered any, stack []byte) {
+	panicked = true
+	defer func() {
+		if panicked {
+			recovered = recover()
+			stack = debug.Stack()
+		}
+	}()
+	fn()
+	panicked = false
+	return
+}
+
+func panicMessage(recovered any) string {
+	if err, ok := recovered.(error); ok {
+		return err.Error()
+	}
+	return fmt.Sprint(recovered)
+}
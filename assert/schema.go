@@ -0,0 +1,37 @@
+package assert
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"src.goblgobl.com/tests/schema"
+)
+
+// The value matches the JSON Schema. value can be anything that
+// serializes to json, or raw json as a []byte / json.RawMessage.
+// The schema can be a map[string]any, raw json, a path to a json
+// file or a *schema.Schema. Every violation is listed.
+func JSONSchema(t *testing.T, value any, expected any) {
+	t.Helper()
+	s, err := schema.Open(expected)
+	if err != nil {
+		Fail(t, "invalid schema: %s", err)
+		return
+	}
+
+	if raw, ok := value.([]byte); ok {
+		value = json.RawMessage(raw)
+	}
+
+	violations := s.Validate(value)
+	if len(violations) == 0 {
+		return
+	}
+
+	lines := make([]string, len(violations))
+	for i, v := range violations {
+		lines[i] = v.String()
+	}
+	Fail(t, "\nvalue does not match the schema:\n  %s", strings.Join(lines, "\n  "))
+}
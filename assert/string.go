@@ -0,0 +1,81 @@
+package assert
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// The string matches the regular expression
+func Matches(t *testing.T, actual string, pattern string) {
+	t.Helper()
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		t.Fatalf("assert.Matches: invalid pattern %q: %s", pattern, err)
+	}
+	if !re.MatchString(actual) {
+		t.Errorf("\nexpected: '%s'\nto match: '%s'", red(actual), pattern)
+		t.FailNow()
+	}
+}
+
+func HasPrefix(t *testing.T, actual string, prefix string) {
+	t.Helper()
+	if !strings.HasPrefix(actual, prefix) {
+		t.Errorf("\nexpected: '%s'\nto start with: '%s'", red(actual), prefix)
+		t.FailNow()
+	}
+}
+
+func HasSuffix(t *testing.T, actual string, suffix string) {
+	t.Helper()
+	if !strings.HasSuffix(actual, suffix) {
+		t.Errorf("\nexpected: '%s'\nto end with: '%s'", red(actual), suffix)
+		t.FailNow()
+	}
+}
+
+func StringNotContains(t *testing.T, actual string, unexpected string) {
+	t.Helper()
+	if i := strings.Index(actual, unexpected); i != -1 {
+		highlighted := actual[:i] + red(unexpected) + actual[i+len(unexpected):]
+		t.Errorf("\nexpected: '%s'\nto not contain: '%s'", highlighted, unexpected)
+		t.FailNow()
+	}
+}
+
+// Equal, ignoring case (unicode case folding)
+func EqualFold(t *testing.T, actual string, expected string) {
+	t.Helper()
+	if !strings.EqualFold(actual, expected) {
+		t.Errorf("\nexpected: '%s'\nto equal (ignoring case): '%s'", green(expected), red(actual))
+		t.FailNow()
+	}
+}
+
+// Equal, showing a unified line diff (- expected, + actual) on failure
+func Multiline(t *testing.T, actual string, expected string) {
+	t.Helper()
+	if actual != expected {
+		t.Errorf("\nstrings differ:\n%s", unifiedDiff(expected, actual))
+		t.FailNow()
+	}
+}
+
+// Equal once runs of whitespace are collapsed into a single space
+// (and leading/trailing whitespace is removed), for generated SQL,
+// templates and the like
+func EqualIgnoringWhitespace(t *testing.T, actual string, expected string) {
+	t.Helper()
+	a := strings.Join(strings.Fields(actual), " ")
+	e := strings.Join(strings.Fields(expected), " ")
+	if a != e {
+		t.Errorf("\nexpected: '%s'\nto equal: '%s'\n(whitespace collapsed)\n%s", e, a, unifiedDiff(wordLines(e), wordLines(a)))
+		t.FailNow()
+	}
+}
+
+// one word per line, so that the diff points at the word which differs
+func wordLines(s string) string {
+	return strings.ReplaceAll(s, " ", "\n")
+}
@@ -1,40 +1,102 @@
 package assert
 
 /*
-Helper to validation a src.goblgobl.com/utils/validation.Result
-but using reflection so we don't create a cyclical dependency
-(ya, that's normal...)
+Helper to validation a src.goblgobl.com/utils/validation.Result.
+Results which implement ValidationResult (which only uses builtin
+types, so implementing it doesn't require importing this package)
+hand over their errors directly. Anything else goes through
+reflection (calling its Errors method) and a json round trip, so
+we don't create a cyclical dependency (ya, that's normal...)
 */
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
+type ValidationError struct {
+	Field string `json:"field,omitempty"`
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+	Data  any    `json:"data,omitempty"`
+}
+
+type ValidationResult interface {
+	EachValidationError(fn func(field string, code int, message string, data any))
+}
+
 type V struct {
 	t      *testing.T
 	json   []byte
-	errors []map[string]any
+	errors []ValidationError
+	// for each Field/Fieldless/FieldMessage call, the indexes of
+	// the errors it could match
+	expectations [][]int
 }
 
 func Validation(t *testing.T, result any) *V {
-	e1 := reflect.ValueOf(result).MethodByName("Errors").Call(nil)[0]
-	data, err := json.MarshalIndent(e1.Interface(), "", " ")
+	t.Helper()
+	if r, ok := result.(ValidationResult); ok {
+		var errors []ValidationError
+		r.EachValidationError(func(field string, code int, message string, data any) {
+			errors = append(errors, ValidationError{Field: field, Code: code, Error: message, Data: data})
+		})
+		return newV(t, errors)
+	}
+
+	if result == nil {
+		t.Fatalf("assert.Validation: result is nil")
+	}
+	method := reflect.ValueOf(result).MethodByName("Errors")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() == 0 {
+		t.Fatalf("assert.Validation: expected a ValidationResult or a value with an Errors() method, got %T", result)
+	}
+	return ValidationErrors(t, method.Call(nil)[0].Interface())
+}
+
+// Like Validation, but for the errors themselves (a []ValidationError
+// or anything that serializes to a list of {field, code, error, data}),
+// e.g. the "invalid" array of a 400 response.
+func ValidationErrors(t *testing.T, errors any) *V {
+	t.Helper()
+	if e, ok := errors.([]ValidationError); ok {
+		return newV(t, e)
+	}
+
+	data, err := json.Marshal(errors)
 	if err != nil {
-		panic(err)
+		t.Fatalf("assert.ValidationErrors: failed to serialize %T: %s", errors, err)
+	}
+
+	var raw []map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		t.Fatalf("assert.ValidationErrors: expected a list of errors, got: %s", data)
 	}
 
-	var e2 []map[string]any
-	if err := json.Unmarshal(data, &e2); err != nil {
-		panic(err)
+	e := make([]ValidationError, len(raw))
+	for i, r := range raw {
+		number, _ := r["code"].(json.Number)
+		code, _ := number.Int64()
+		e[i].Field, _ = r["field"].(string)
+		e[i].Error, _ = r["error"].(string)
+		e[i].Code = int(code)
+		e[i].Data = r["data"]
 	}
+	return newV(t, e)
+}
 
+func newV(t *testing.T, errors []ValidationError) *V {
+	data, _ := json.MarshalIndent(errors, "", " ")
 	return &V{
 		t:      t,
 		json:   data,
-		errors: e2,
+		errors: errors,
 	}
 }
 
@@ -45,57 +107,60 @@ func (v *V) Fieldless(meta any) *V {
 // Can pass:
 // a code, e.g. Field("name", 10)
 // a code with data, e.g. Field("name", 10, map[string]any{max: 20})
+// a ValidationError, e.g. Field("name", assert.ValidationError{Code: 10})
 // anything else that we'll json serialize and compare with the error, e.g: Field(name, validation.Invalid{Code: 300})
+//
+// The field can be a nested path, e.g. items.2.name, where * matches
+// any one segment, e.g. items.*.name
 func (v *V) Field(expectedField string, invalid ...any) *V {
 	t := v.t
 	t.Helper()
 
-	var expectedCode int
-	var expectedData map[string]any
-	{
-		if code, ok := invalid[0].(int); ok {
-			expectedCode = code
-			if len(invalid) == 2 {
-				expectedData = invalid[1].(map[string]any)
-			}
-		} else {
-			bytes, err := json.Marshal(invalid[0])
-			if err != nil {
-				panic(err)
-			}
-			var expectedError map[string]any
-			if err := json.Unmarshal(bytes, &expectedError); err != nil {
-				panic(err)
-			}
-			expectedCode = int(expectedError["code"].(float64))
-			expectedData, _ = expectedError["data"].(map[string]any)
-		}
+	if len(invalid) == 0 {
+		t.Fatalf("assert.V.Field(%q): missing the expected code", expectedField)
 	}
 
-	for _, error := range v.errors {
-		data := error["data"]
-		field := error["field"]
-
-		if field == nil {
-			field = ""
+	var expectedCode int
+	var expectedData any
+	switch e := invalid[0].(type) {
+	case int:
+		expectedCode = e
+		if len(invalid) == 2 {
+			expectedData = invalid[1]
 		}
-
-		if field != expectedField {
-			continue
+	case ValidationError:
+		expectedCode, expectedData = e.Code, e.Data
+	default:
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatalf("assert.V.Field(%q): failed to serialize %T: %s", expectedField, e, err)
 		}
+		var expectedError map[string]any
+		json.Unmarshal(data, &expectedError)
+		code, ok := expectedError["code"].(float64)
+		if !ok {
+			t.Fatalf("assert.V.Field(%q): expected a code, got %T (%s)", expectedField, e, data)
+		}
+		expectedCode = int(code)
+		expectedData = expectedError["data"]
+	}
 
-		if int(error["code"].(float64)) != expectedCode {
+	candidates := make([]int, 0, 1)
+	for i, error := range v.errors {
+		if !fieldMatches(expectedField, error.Field) {
 			continue
 		}
-
-		if (data == nil && expectedData != nil) || (data != nil && expectedData == nil) {
+		if error.Code != expectedCode {
 			continue
 		}
-
-		if data != nil && expectedData != nil && !reflect.DeepEqual(data.(map[string]any), expectedData) {
+		if !dataEqual(error.Data, expectedData) {
 			continue
 		}
+		candidates = append(candidates, i)
+	}
 
+	if len(candidates) > 0 {
+		v.match(candidates)
 		return v
 	}
 
@@ -115,15 +180,19 @@ func (v *V) FieldMessage(expectedField string, expectedMessage string) *V {
 	t := v.t
 	t.Helper()
 
-	for _, error := range v.errors {
-		field := error["field"]
-
-		if field != expectedField {
+	candidates := make([]int, 0, 1)
+	for i, error := range v.errors {
+		if !fieldMatches(expectedField, error.Field) {
 			continue
 		}
-		if error["error"].(string) != expectedMessage {
+		if error.Error != expectedMessage {
 			continue
 		}
+		candidates = append(candidates, i)
+	}
+
+	if len(candidates) > 0 {
+		v.match(candidates)
 		return v
 	}
 
@@ -141,12 +210,12 @@ func (v *V) FieldsHaveNoErrors(noFields ...string) *V {
 	t.Helper()
 
 	for _, error := range v.errors {
-		field := error["field"]
-		if field == nil {
+		field := error.Field
+		if field == "" {
 			continue
 		}
 		for _, noField := range noFields {
-			if field == noField {
+			if fieldMatches(noField, field) {
 				t.Errorf("Expected no error for field '%s', but got:\n%v", field, error)
 				t.FailNow()
 			}
@@ -154,3 +223,158 @@ func (v *V) FieldsHaveNoErrors(noFields ...string) *V {
 	}
 	return v
 }
+
+// Every error was matched by a previous Field/Fieldless/FieldMessage
+func (v *V) Only() *V {
+	t := v.t
+	t.Helper()
+
+	unmatched := v.Unmatched()
+	if len(unmatched) == 0 {
+		return v
+	}
+
+	lines := make([]string, len(unmatched))
+	for i, error := range unmatched {
+		data, _ := json.Marshal(error)
+		lines[i] = string(data)
+	}
+	t.Errorf("\nunexpected validation errors:\n  %s\n\ngot: %s", strings.Join(lines, "\n  "), string(v.json))
+	t.FailNow()
+	return v
+}
+
+// There are exactly n errors
+func (v *V) Count(n int) *V {
+	t := v.t
+	t.Helper()
+	if len(v.errors) != n {
+		t.Errorf("\nexpected %d validation errors, got %d\n\ngot: %s", n, len(v.errors), string(v.json))
+		t.FailNow()
+	}
+	return v
+}
+
+// There are no errors
+func (v *V) None() *V {
+	t := v.t
+	t.Helper()
+	if len(v.errors) != 0 {
+		t.Errorf("\nexpected no validation errors\n\ngot: %s", string(v.json))
+		t.FailNow()
+	}
+	return v
+}
+
+// The field (which can be a path with wildcards) has exactly n errors
+func (v *V) FieldCount(expectedField string, n int) *V {
+	t := v.t
+	t.Helper()
+
+	count := 0
+	for _, error := range v.errors {
+		if fieldMatches(expectedField, error.Field) {
+			count++
+		}
+	}
+	if count != n {
+		t.Errorf("\nexpected %d validation errors for field '%s', got %d\n\ngot: %s", n, expectedField, count, string(v.json))
+		t.FailNow()
+	}
+	return v
+}
+
+// The errors which no Field/Fieldless/FieldMessage call matched.
+// Each call matches at most one error, picked so that as many
+// errors as possible are matched (a wildcard and an exact path
+// which fit the same error don't both claim it).
+func (v *V) Unmatched() []ValidationError {
+	owners := v.assign()
+	var unmatched []ValidationError
+	for i, error := range v.errors {
+		if owners[i] == -1 {
+			unmatched = append(unmatched, error)
+		}
+	}
+	return unmatched
+}
+
+// The JSON of all the errors, for failure messages
+func (v *V) String() string {
+	return string(v.json)
+}
+
+func (v *V) match(candidates []int) {
+	v.expectations = append(v.expectations, candidates)
+}
+
+// A maximum bipartite matching of expectations to errors (Kuhn's
+// algorithm, there are only ever a handful of each). Returns, for
+// each error, the index of the expectation it's assigned to, or -1.
+func (v *V) assign() []int {
+	owners := make([]int, len(v.errors))
+	for i := range owners {
+		owners[i] = -1
+	}
+
+	var augment func(expectation int, seen []bool) bool
+	augment = func(expectation int, seen []bool) bool {
+		for _, i := range v.expectations[expectation] {
+			if seen[i] {
+				continue
+			}
+			seen[i] = true
+			if owners[i] == -1 || augment(owners[i], seen) {
+				owners[i] = expectation
+				return true
+			}
+		}
+		return false
+	}
+
+	for expectation := range v.expectations {
+		augment(expectation, make([]bool, len(v.errors)))
+	}
+	return owners
+}
+
+// Data from the json fallback has json.Numbers where the expected
+// data has ints, so compare the json when they aren't deeply equal
+func dataEqual(actual any, expected any) bool {
+	if actual == nil || expected == nil {
+		return actual == nil && expected == nil
+	}
+	if reflect.DeepEqual(actual, expected) {
+		return true
+	}
+	a, err1 := json.Marshal(actual)
+	e, err2 := json.Marshal(expected)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	var na, ne any
+	json.Unmarshal(a, &na)
+	json.Unmarshal(e, &ne)
+	return reflect.DeepEqual(na, ne)
+}
+
+// expected can use * to match any one segment of a dotted path
+func fieldMatches(expected string, actual string) bool {
+	if expected == actual {
+		return true
+	}
+	if !strings.Contains(expected, "*") {
+		return false
+	}
+	e := strings.Split(expected, ".")
+	a := strings.Split(actual, ".")
+	if len(e) != len(a) {
+		return false
+	}
+	for i, segment := range e {
+		if segment != "*" && segment != a[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package assert
+
+import "testing"
+
+func Test_Validation_Only_WildcardAndExact(t *testing.T) {
+	errors := []ValidationError{
+		{Field: "items.1.name", Code: 1},
+		{Field: "items.0.name", Code: 1},
+	}
+
+	// the wildcard must not claim items.1.name when the exact path needs it
+	ValidationErrors(t, errors).
+		Field("items.*.name", 1).
+		Field("items.1.name", 1).
+		Only()
+
+	ValidationErrors(t, errors).
+		Field("items.1.name", 1).
+		Field("items.*.name", 1).
+		Only()
+}
+
+func Test_Validation_Unmatched(t *testing.T) {
+	errors := []ValidationError{
+		{Field: "items.0.name", Code: 1},
+		{Field: "items.1.name", Code: 1},
+		{Field: "items.1.name", Code: 2},
+	}
+
+	v := ValidationErrors(t, errors).Field("items.*.name", 1).Field("items.0.name", 1)
+	unmatched := v.Unmatched()
+	if len(unmatched) != 1 || unmatched[0].Code != 2 {
+		t.Fatalf("expected only the code 2 error to be unmatched, got %v", unmatched)
+	}
+
+	// the same expectation twice still only matches one error each
+	v = ValidationErrors(t, errors).Field("items.*.name", 1).Field("items.*.name", 1).Field("items.*.name", 1)
+	unmatched = v.Unmatched()
+	if len(unmatched) != 1 || unmatched[0].Code != 2 {
+		t.Fatalf("expected only the code 2 error to be unmatched, got %v", unmatched)
+	}
+}
+
+type result []ValidationError
+
+func (r result) EachValidationError(fn func(field string, code int, message string, data any)) {
+	for _, e := range r {
+		fn(e.Field, e.Code, e.Error, e.Data)
+	}
+}
+
+func Test_Validation_ValidationResult(t *testing.T) {
+	r := result{
+		{Field: "name", Code: 1, Error: "required"},
+		{Field: "age", Code: 2, Error: "too low", Data: map[string]any{"min": 18}},
+	}
+	Validation(t, r).
+		FieldMessage("name", "required").
+		Field("age", 2, map[string]any{"min": 18}).
+		Only()
+}
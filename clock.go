@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+// A controllable clock. Code under test takes a func() time.Time
+// (clock.Now) instead of calling time.Now, and the test freezes
+// or advances it:
+//
+//	clock := tests.NewClock().Freeze().Use(t)
+//	cache.Now = clock.Now
+//	clock.Advance(time.Hour)
+//	assert.Nowish(t, cache.ExpiredAt())
+//
+// Until frozen, it follows the real time (shifted by any Advance).
+type Clock struct {
+	lock   sync.Mutex
+	frozen bool
+	at     time.Time
+	offset time.Duration
+}
+
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.frozen {
+		return c.at
+	}
+	return time.Now().Add(c.offset)
+}
+
+// Stops the clock at the given time, or at its current time
+func (c *Clock) Freeze(at ...time.Time) *Clock {
+	now := c.Now()
+	if len(at) == 1 {
+		now = at[0]
+	}
+	c.lock.Lock()
+	c.frozen, c.at = true, now
+	c.lock.Unlock()
+	return c
+}
+
+// Restarts a frozen clock from its current time
+func (c *Clock) Unfreeze() *Clock {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.frozen {
+		c.frozen = false
+		c.offset = c.at.Sub(time.Now())
+	}
+	return c
+}
+
+// Moves the clock forward (or backwards, for a negative d)
+func (c *Clock) Advance(d time.Duration) *Clock {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.frozen {
+		c.at = c.at.Add(d)
+	} else {
+		c.offset += d
+	}
+	return c
+}
+
+// Makes assert.Nowish, within t and its subtests, compare against
+// this clock until the test ends
+func (c *Clock) Use(t *testing.T) *Clock {
+	assert.SetClock(t, c.Now)
+	return c
+}
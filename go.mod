@@ -4,6 +4,7 @@ go 1.21
 
 require (
 	github.com/google/uuid v1.5.0
+	github.com/klauspost/compress v1.17.4
 	github.com/valyala/fasthttp v1.51.0
 	src.goblgobl.com/sqlite v0.0.4
 	src.goblgobl.com/utils v0.0.9
@@ -12,7 +13,6 @@ require (
 require (
 	github.com/andybalholm/brotli v1.0.6 // indirect
 	github.com/goccy/go-json v0.10.2 // indirect
-	github.com/klauspost/compress v1.17.4 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	golang.org/x/sync v0.5.0 // indirect
 )
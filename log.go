@@ -0,0 +1,339 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/log"
+)
+
+// A single parsed log entry. The log package writes
+//
+//	l=info t=1672531200 c=ctx key1=value1 key2="value 2"
+//
+// (or the json equivalent), which we break into a level (l),
+// a context/message (c) and the remaining fields.
+type LogRecord struct {
+	Raw     string
+	Level   string
+	Context string
+	Route   string
+	Fields  map[string]string
+}
+
+// An io.Writer that parses everything written to it into
+// a list of LogRecords, and provides assertions on them.
+type LogRecorder struct {
+	t       *testing.T
+	lock    sync.Mutex
+	partial []byte
+	records []LogRecord
+}
+
+// A recorder which can be given, as a plain io.Writer, to
+// whatever the code under test logs to (which, unlike Capture,
+// only records what that code logs, even from parallel tests).
+func NewLogRecorder(t *testing.T) *LogRecorder {
+	return &LogRecorder{t: t}
+}
+
+// Capture (and parse) everything logged while fn executes
+func CaptureLogs(t *testing.T, fn func()) *LogRecorder {
+	recorder := NewLogRecorder(t)
+	recorder.Capture(fn)
+	return recorder
+}
+
+// Capture everything logged through log.Out from now until the
+// end of the test. Like Capture, this is process-wide.
+func RecordLogs(t *testing.T) *LogRecorder {
+	recorder := NewLogRecorder(t)
+	globalLogs.add(recorder)
+	t.Cleanup(func() { globalLogs.remove(recorder) })
+	return recorder
+}
+
+// Kept for existing callers, returns the raw log text. Prefer
+// CaptureLogs
+func CaptureLog(fn func()) string {
+	str := &strings.Builder{}
+	captureGlobal(str, fn)
+	return str.String()
+}
+
+// Capture (and parse) everything logged through log.Out while fn
+// executes, including by goroutines fn starts. log.Out is
+// process-wide, so this also captures what parallel tests log
+// at the same time; for those, give the recorder to the code
+// under test as its writer instead.
+func (r *LogRecorder) Capture(fn func()) *LogRecorder {
+	captureGlobal(r, fn)
+	return r
+}
+
+func (r *LogRecorder) Write(data []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.partial = append(r.partial, data...)
+	for {
+		i := bytes.IndexByte(r.partial, '\n')
+		if i == -1 {
+			break
+		}
+		if line := bytes.TrimSpace(r.partial[:i]); len(line) > 0 {
+			r.records = append(r.records, ParseLogRecord(string(line)))
+		}
+		r.partial = r.partial[i+1:]
+	}
+	return len(data), nil
+}
+
+func (r *LogRecorder) Records() []LogRecord {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	records := r.records
+	if line := bytes.TrimSpace(r.partial); len(line) > 0 {
+		records = append(records[:len(records):len(records)], ParseLogRecord(string(line)))
+	}
+	return records
+}
+
+func (r *LogRecorder) Reset() *LogRecorder {
+	r.lock.Lock()
+	r.records = nil
+	r.partial = nil
+	r.lock.Unlock()
+	return r
+}
+
+func (r *LogRecorder) String() string {
+	var sb strings.Builder
+	for _, record := range r.Records() {
+		sb.WriteString(record.Raw)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Finds records of the given level (any level, if "") which
+// contain all of the given fields
+func (r *LogRecorder) Find(level string, fields map[string]any) []LogRecord {
+	var found []LogRecord
+	for _, record := range r.Records() {
+		if record.Matches(level, fields) {
+			found = append(found, record)
+		}
+	}
+	return found
+}
+
+// There's at least one record with the given level (any level,
+// if "") containing all of the given fields. A "c" field matches
+// the context and a "route" field the route.
+func (r *LogRecorder) ExpectLog(level string, fields map[string]any) *LogRecorder {
+	r.t.Helper()
+	if len(r.Find(level, fields)) == 0 {
+		assert.Fail(r.t, "\nexpected log:\n  level=%s\n  fields=%v\n\ngot:\n%s", level, fields, r)
+	}
+	return r
+}
+
+// There's no record with the given level containing all of the
+// given fields
+func (r *LogRecorder) ExpectNoLog(level string, fields map[string]any) *LogRecorder {
+	r.t.Helper()
+	if len(r.Find(level, fields)) != 0 {
+		assert.Fail(r.t, "\nexpected no log:\n  level=%s\n  fields=%v\n\ngot:\n%s", level, fields, r)
+	}
+	return r
+}
+
+// Nothing was logged at the error or fatal level
+func (r *LogRecorder) ExpectNoErrors() *LogRecorder {
+	r.t.Helper()
+	for _, record := range r.Records() {
+		if record.Level == "error" || record.Level == "fatal" {
+			assert.Fail(r.t, "\nexpected no error logs, got:\n%s", r)
+		}
+	}
+	return r
+}
+
+// Exactly n records were logged
+func (r *LogRecorder) ExpectCount(expected int) *LogRecorder {
+	r.t.Helper()
+	if actual := len(r.Records()); actual != expected {
+		assert.Fail(r.t, "\nexpected %d log records, got %d:\n%s", expected, actual, r)
+	}
+	return r
+}
+
+func (l LogRecord) Matches(level string, fields map[string]any) bool {
+	if level != "" && l.Level != level {
+		return false
+	}
+	for key, expected := range fields {
+		var actual string
+		var exists bool
+		switch key {
+		case "l":
+			actual, exists = l.Level, true
+		case "c":
+			actual, exists = l.Context, true
+		case "route":
+			actual, exists = l.Route, l.Route != ""
+		default:
+			actual, exists = l.Fields[key]
+		}
+		if !exists || actual != fmt.Sprint(expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parses a single line written by the log package, either in its
+// key=value form or json
+func ParseLogRecord(line string) LogRecord {
+	var fields map[string]string
+	if strings.HasPrefix(line, "{") {
+		fields = parseJsonLog(line)
+	} else {
+		fields = parseKvLog(line)
+	}
+
+	record := LogRecord{Raw: line, Fields: fields}
+	record.Level = fields["l"]
+	record.Context = fields["c"]
+	record.Route = fields["route"]
+	delete(fields, "l")
+	delete(fields, "c")
+	delete(fields, "route")
+	return record
+}
+
+func parseJsonLog(line string) map[string]string {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return map[string]string{}
+	}
+	fields := make(map[string]string, len(m))
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			fields[k] = v
+		case nil:
+			fields[k] = ""
+		default:
+			data, _ := json.Marshal(v)
+			fields[k] = string(data)
+		}
+	}
+	return fields
+}
+
+func parseKvLog(line string) map[string]string {
+	fields := make(map[string]string)
+	for len(line) > 0 {
+		line = strings.TrimLeft(line, " ")
+		eq := strings.IndexByte(line, '=')
+		if eq == -1 {
+			break
+		}
+		key := line[:eq]
+		line = line[eq+1:]
+
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			end := quotedEnd(line)
+			if unquoted, err := strconv.Unquote(line[:end]); err == nil {
+				value = unquoted
+			} else {
+				value = strings.Trim(line[:end], `"`)
+			}
+			line = line[end:]
+		} else if sp := strings.IndexByte(line, ' '); sp == -1 {
+			value, line = line, ""
+		} else {
+			value, line = line[:sp], line[sp:]
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
+// index just past the closing quote of a quoted value which
+// starts at s[0]
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// log.Out is process-wide, so capturing through it captures
+// what every goroutine logs. While anything is capturing, log.Out
+// is replaced by a writer which copies each write to all of the
+// captures. The previous log.Out is restored once the last one
+// ends (so, unlike a plain swap, captures can be nested/overlap).
+var globalLogs = &logTee{}
+
+type logTee struct {
+	lock     sync.Mutex
+	previous io.Writer
+	writers  []io.Writer
+}
+
+func captureGlobal(w io.Writer, fn func()) {
+	globalLogs.add(w)
+	defer globalLogs.remove(w)
+	fn()
+}
+
+func (l *logTee) add(w io.Writer) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.writers) == 0 {
+		l.previous = log.Out
+		log.Out = l
+	}
+	l.writers = append(l.writers, w)
+}
+
+func (l *logTee) remove(w io.Writer) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for i := len(l.writers) - 1; i >= 0; i-- {
+		if l.writers[i] == w {
+			l.writers = append(l.writers[:i:i], l.writers[i+1:]...)
+			break
+		}
+	}
+	if len(l.writers) == 0 {
+		log.Out = l.previous
+		l.previous = nil
+	}
+}
+
+func (l *logTee) Write(data []byte) (int, error) {
+	l.lock.Lock()
+	writers := l.writers
+	l.lock.Unlock()
+	for _, w := range writers {
+		w.Write(data)
+	}
+	return len(data), nil
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/log"
+)
+
+func Test_CaptureLog_RestoresOut(t *testing.T) {
+	previous := log.Out
+	defer func() { log.Out = previous }()
+
+	out := &strings.Builder{}
+	log.Out = out
+	captured := CaptureLog(func() {
+		log.Out.Write([]byte("l=info c=inside\n"))
+	})
+	assert.Equal(t, captured, "l=info c=inside\n")
+	assert.True(t, log.Out == out)
+
+	log.Out.Write([]byte("l=info c=after\n"))
+	assert.Equal(t, out.String(), "l=info c=after\n")
+}
+
+func Test_Capture_OtherGoroutines(t *testing.T) {
+	recorder := CaptureLogs(t, func() {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Out.Write([]byte("l=error c=worker id=9\n"))
+		}()
+		wg.Wait()
+	})
+	recorder.ExpectCount(1).ExpectLog("error", map[string]any{"c": "worker", "id": 9})
+}
+
+func Test_Capture_Nested(t *testing.T) {
+	var inner *LogRecorder
+	outer := CaptureLogs(t, func() {
+		log.Out.Write([]byte("l=info c=first\n"))
+		inner = CaptureLogs(t, func() {
+			log.Out.Write([]byte("l=info c=second\n"))
+		})
+		log.Out.Write([]byte("l=info c=third\n"))
+	})
+	outer.ExpectCount(3)
+	inner.ExpectCount(1).ExpectLog("info", map[string]any{"c": "second"})
+}
+
+func Test_LogRecorder_Writer(t *testing.T) {
+	recorder := NewLogRecorder(t)
+	recorder.Write([]byte(`l=warn c=cache key="a b"` + "\n" + `{"l":"info","c":"json","n":2}`))
+	recorder.ExpectCount(2).
+		ExpectLog("warn", map[string]any{"c": "cache", "key": "a b"}).
+		ExpectLog("info", map[string]any{"n": 2}).
+		ExpectNoLog("error", nil)
+}
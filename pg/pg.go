@@ -0,0 +1,188 @@
+package pg
+
+/*
+A ready-made PostgreSQL / CockroachDB database for tests.
+It satisfies both tests.TestableDB and factory.SQLStorage:
+
+	import _ "github.com/jackc/pgx/v5/stdlib"
+
+	db := pg.Postgres("myapp_test")
+	factory.DB = db
+
+It's built on database/sql, so it works with whichever driver
+the project already uses (registered as Driver, "pgx" by default)
+without adding one to this module.
+
+The connection string comes from tests.PG / tests.CR, so
+GOBL_TEST_PG and GOBL_TEST_CR can point it to whatever
+(local, docker, embedded) server is available.
+*/
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"src.goblgobl.com/tests"
+	"src.goblgobl.com/utils/typed"
+)
+
+// The database/sql driver used by New, Postgres and Cockroach
+var Driver = "pgx"
+
+type DB struct {
+	url string
+	db  *sql.DB
+}
+
+func New(url string) (DB, error) {
+	return Open(Driver, url)
+}
+
+// Connects using the given database/sql driver
+func Open(driver string, url string) (DB, error) {
+	db, err := sql.Open(driver, url)
+	if err != nil {
+		return DB{}, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return DB{}, err
+	}
+	return DB{url: url, db: db}, nil
+}
+
+// Connects to the dbName database using tests.PG(dbName)
+func Postgres(dbName string) DB {
+	return mustNew(tests.PG(dbName))
+}
+
+// Connects to the dbName database using tests.CR(dbName)
+func Cockroach(dbName string) DB {
+	return mustNew(tests.CR(dbName))
+}
+
+func mustNew(url string) DB {
+	db, err := New(url)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// The underlying connection, for anything not exposed here
+func (db DB) DB() *sql.DB {
+	return db.db
+}
+
+func (db DB) URL() string {
+	return db.url
+}
+
+func (db DB) Close() error {
+	return db.db.Close()
+}
+
+func (db DB) MustExec(sql string, args ...any) {
+	if _, err := db.db.Exec(sql, args...); err != nil {
+		panic(err)
+	}
+}
+
+// sql.ErrNoRows (see IsNotFound) when the query returns no row
+func (db DB) RowToMap(sql string, args ...any) (typed.Typed, error) {
+	rows, err := db.RowsToMap(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errNoRows
+	}
+	return rows[0], nil
+}
+
+func (db DB) RowsToMap(sql string, args ...any) ([]typed.Typed, error) {
+	rows, err := db.db.Query(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]typed.Typed, 0, 1)
+	for rows.Next() {
+		values := make([]any, len(columns))
+		pointers := make([]any, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(typed.Typed, len(columns))
+		for i, column := range columns {
+			value, err := convert(column.DatabaseTypeName(), values[i])
+			if err != nil {
+				return nil, err
+			}
+			row[column.Name()] = value
+		}
+		results = append(results, row)
+	}
+	return results, rows.Err()
+}
+
+func (db DB) Placeholder(i int) string {
+	return "$" + strconv.Itoa(i+1)
+}
+
+func (db DB) IsNotFound(err error) bool {
+	return errors.Is(err, errNoRows)
+}
+
+// factory.JSON values are stored as jsonb. The driver happily
+// casts a string parameter to jsonb (but would send []byte as bytea)
+func (db DB) JSON(input any) (any, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// the methods' sql parameter shadows the package
+var errNoRows = sql.ErrNoRows
+
+// json/jsonb columns are decoded (so that row.Object("data") works),
+// text that a driver returns as []byte becomes a string, bytea
+// stays []byte
+func convert(databaseType string, value any) (any, error) {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return value, nil
+	}
+
+	switch strings.ToUpper(databaseType) {
+	case "JSON", "JSONB":
+		var decoded any
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	case "BYTEA":
+		return data, nil
+	}
+	return string(data), nil
+}
@@ -0,0 +1,176 @@
+package pg
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"src.goblgobl.com/tests"
+	"src.goblgobl.com/tests/assert"
+)
+
+// A database/sql stand-in for postgres: queries are looked up
+// in fakeResults, execs are recorded in fakeExecs
+var (
+	fakeExecs   []string
+	fakeResults = map[string]fakeRows{
+		"select 1 as id, 'leto' as name, '{\"power\": 9}' as meta, 'ab' as raw": {
+			columns: []string{"id", "name", "meta", "raw"},
+			types:   []string{"INT8", "TEXT", "JSONB", "BYTEA"},
+			values:  [][]driver.Value{{int64(1), []byte("leto"), []byte(`{"power": 9}`), []byte("ab")}},
+		},
+		"select * from users where id = $1": {
+			columns: []string{"id"},
+			types:   []string{"INT8"},
+		},
+		"select id from users": {
+			columns: []string{"id"},
+			types:   []string{"INT8"},
+			values:  [][]driver.Value{{int64(1)}, {int64(2)}},
+		},
+	}
+)
+
+func init() {
+	sql.Register("fakepg", fakeDriver{})
+}
+
+func Test_RowToMap(t *testing.T) {
+	db := fakeDB(t)
+	row, err := db.RowToMap("select 1 as id, 'leto' as name, '{\"power\": 9}' as meta, 'ab' as raw")
+	assert.Nil(t, err)
+	assert.Equal(t, row.Int("id"), 1)
+	assert.Equal(t, row.String("name"), "leto")
+	assert.Equal(t, row.Object("meta").Int("power"), 9)
+	assert.Bytes(t, row["raw"].([]byte), []byte("ab"))
+}
+
+func Test_RowToMap_NotFound(t *testing.T) {
+	db := fakeDB(t)
+	_, err := db.RowToMap("select * from users where id = $1", 3)
+	assert.True(t, db.IsNotFound(err))
+	assert.Nil(t, tests.Row(db, "select * from users where id = $1", 3))
+	assert.False(t, db.IsNotFound(errors.New("other")))
+}
+
+func Test_RowsToMap(t *testing.T) {
+	db := fakeDB(t)
+	rows := tests.Rows(db, "select id from users")
+	assert.Equal(t, len(rows), 2)
+	assert.Equal(t, rows[1].Int("id"), 2)
+}
+
+func Test_MustExec(t *testing.T) {
+	db := fakeDB(t)
+	fakeExecs = nil
+	db.MustExec("truncate users")
+	assert.List(t, fakeExecs, []string{"truncate users"})
+
+	defer func() {
+		assert.StringContains(t, fmt.Sprint(recover()), "exec failed")
+	}()
+	db.MustExec("fail")
+}
+
+func Test_JSON_And_Placeholder(t *testing.T) {
+	db := fakeDB(t)
+	value, err := db.JSON(map[string]any{"a": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, value.(string), `{"a":1}`)
+	assert.Equal(t, db.Placeholder(0), "$1")
+}
+
+// Against a real server, when one is configured (GOBL_TEST_PG) and
+// the test binary has a driver registered as Driver
+func Test_Postgres(t *testing.T) {
+	if os.Getenv("GOBL_TEST_PG") == "" || !hasDriver(Driver) {
+		t.Skip("GOBL_TEST_PG not set or no " + Driver + " driver registered")
+	}
+	db := Postgres("postgres")
+	defer db.Close()
+	row, err := db.RowToMap("select 1 as id, $1::jsonb as meta", mustJSON(t, db, map[string]any{"power": 9}))
+	assert.Nil(t, err)
+	assert.Equal(t, row.Int("id"), 1)
+	assert.Equal(t, row.Object("meta").Int("power"), 9)
+	_, err = db.RowToMap("select 1 where false")
+	assert.True(t, db.IsNotFound(err))
+}
+
+func fakeDB(t *testing.T) DB {
+	db, err := Open("fakepg", "postgres://fake")
+	assert.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func mustJSON(t *testing.T, db DB, value any) any {
+	j, err := db.JSON(value)
+	assert.Nil(t, err)
+	return j
+}
+
+func hasDriver(name string) bool {
+	for _, d := range sql.Drivers() {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.query == "fail" {
+		return nil, errors.New("exec failed")
+	}
+	fakeExecs = append(fakeExecs, s.query)
+	return driver.RowsAffected(0), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, ok := fakeResults[strings.TrimSpace(s.query)]
+	if !ok {
+		return nil, errors.New("unknown query: " + s.query)
+	}
+	return &rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	types   []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string                       { return r.columns }
+func (r *fakeRows) Close() error                            { return nil }
+func (r *fakeRows) ColumnTypeDatabaseTypeName(i int) string { return r.types[i] }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
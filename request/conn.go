@@ -0,0 +1,163 @@
+package request
+
+// The connection behind the *fasthttp.RequestCtx created by
+// RequestBuilder.Conn. Without it, RemoteIP() is always 0.0.0.0,
+// IsTLS() is always false and every request has the same ConnID.
+
+import (
+	"crypto/tls"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+)
+
+var (
+	localAddr  = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 80}
+	remoteAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40000}
+)
+
+// The client's address, as "ip:port" or just "ip"
+func (r RequestBuilder) RemoteAddr(addr string) RequestBuilder {
+	r.t.Helper()
+	ap, err := netip.ParseAddrPort(addr)
+	if err != nil {
+		ip, err := netip.ParseAddr(addr)
+		if err != nil {
+			assert.Fail(r.t, "invalid RemoteAddr: %s", addr)
+		}
+		ap = netip.AddrPortFrom(ip, 0)
+	}
+	r.remoteAddr = net.TCPAddrFromAddrPort(ap)
+	return r
+}
+
+// Makes the request over TLS: ctx.IsTLS() is true,
+// ctx.TLSConnectionState() returns the state and the URI's
+// scheme is https. With no state, a completed TLS 1.3 handshake
+// is used. The ServerName defaults to the request's host.
+func (r RequestBuilder) TLS(state ...tls.ConnectionState) RequestBuilder {
+	s := tls.ConnectionState{
+		Version:           tls.VersionTLS13,
+		HandshakeComplete: true,
+		CipherSuite:       tls.TLS_AES_128_GCM_SHA256,
+	}
+	if len(state) == 1 {
+		s = state[0]
+	}
+	r.tls = &s
+	return r
+}
+
+// By default, every request gets a new (unique) connection id.
+// Use this to make requests look like they came over the same
+// (keepalive) connection
+func (r RequestBuilder) ConnID(id uint64) RequestBuilder {
+	r.connID = &id
+	return r
+}
+
+// By default, the connection is established when the request
+// is created
+func (r RequestBuilder) ConnTime(connTime time.Time) RequestBuilder {
+	r.connTime = connTime
+	return r
+}
+
+func (r RequestBuilder) initConn(ctx *fasthttp.RequestCtx) {
+	remote := r.remoteAddr
+	if remote == nil {
+		remote = remoteAddr
+	}
+
+	var conn net.Conn = &fakeConn{remote: remote}
+	if r.tls != nil {
+		state := *r.tls
+		if state.ServerName == "" {
+			state.ServerName = r.host
+			if state.ServerName == "" {
+				state.ServerName = "test.goblgobl.local"
+			}
+		}
+		conn = &fakeTLSConn{fakeConn: conn.(*fakeConn), state: state}
+	}
+	ctx.Init2(conn, testLogger{r.t}, true)
+	// what ctx.RemoteAddr() (and RemoteIP()) return, the conn's
+	// RemoteAddr is kept the same for ctx.Conn().RemoteAddr()
+	ctx.SetRemoteAddr(remote)
+
+	// fasthttp doesn't let us set these, and Init2 has already
+	// assigned a unique id and the current time
+	if r.connID != nil {
+		setCtxField(r.t, ctx, "connID", *r.connID)
+	}
+	if !r.connTime.IsZero() {
+		setCtxField(r.t, ctx, "connTime", r.connTime)
+	}
+}
+
+// The unexported fasthttp.RequestCtx fields which setCtxField writes
+// (and their types). conn_test.go checks these against fasthttp, so
+// that an upgrade which changes them fails there first.
+var ctxFields = map[string]reflect.Type{
+	"connID":   reflect.TypeOf(uint64(0)),
+	"connTime": reflect.TypeOf(time.Time{}),
+}
+
+// Writes one of fasthttp's unexported fields, failing clearly if
+// this version of fasthttp doesn't have it (or has a different type)
+func setCtxField(t *testing.T, ctx *fasthttp.RequestCtx, name string, value any) {
+	t.Helper()
+	field := reflect.ValueOf(ctx).Elem().FieldByName(name)
+	if !field.IsValid() {
+		t.Fatalf("request: fasthttp.RequestCtx has no %s field, this version of fasthttp isn't supported by ConnID/ConnTime", name)
+	}
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(field.Type()) {
+		t.Fatalf("request: fasthttp.RequestCtx.%s is a %s (expected %s), this version of fasthttp isn't supported by ConnID/ConnTime", name, field.Type(), v.Type())
+	}
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(v)
+}
+
+// Only the addresses are ever used, anything else would panic
+// on the nil net.Conn, which is what we want
+type fakeConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *fakeConn) LocalAddr() net.Addr {
+	return localAddr
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// fasthttp detects TLS through these two methods
+type fakeTLSConn struct {
+	*fakeConn
+	state tls.ConnectionState
+}
+
+func (c *fakeTLSConn) Handshake() error {
+	return nil
+}
+
+func (c *fakeTLSConn) ConnectionState() tls.ConnectionState {
+	return c.state
+}
+
+// fasthttp.Logger which goes to the test's log
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Printf(format string, args ...any) {
+	l.t.Logf(format, args...)
+}
@@ -0,0 +1,62 @@
+package request
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+)
+
+// ConnID and ConnTime write these unexported fields, there's no
+// public way to set them
+func Test_Conn_FasthttpFields(t *testing.T) {
+	ctxType := reflect.TypeOf(fasthttp.RequestCtx{})
+	for name, expected := range ctxFields {
+		field, ok := ctxType.FieldByName(name)
+		if !ok {
+			t.Fatalf("fasthttp.RequestCtx no longer has a %s field, ConnID/ConnTime need to be updated for this version of fasthttp", name)
+		}
+		if field.Type != expected {
+			t.Fatalf("fasthttp.RequestCtx.%s is now a %s (was %s), ConnID/ConnTime need to be updated for this version of fasthttp", name, field.Type, expected)
+		}
+	}
+}
+
+func Test_Conn_Defaults(t *testing.T) {
+	conn1 := Req(t).Conn()
+	conn2 := Req(t).Conn()
+	assert.Equal(t, conn1.RemoteIP().String(), "127.0.0.1")
+	assert.False(t, conn1.IsTLS())
+	assert.NotEqual(t, conn1.ConnID(), conn2.ConnID())
+	assert.Timeish(t, conn1.ConnTime(), time.Now())
+}
+
+func Test_Conn_RemoteAddr(t *testing.T) {
+	conn := Req(t).RemoteAddr("10.0.0.9:1234").Conn()
+	assert.Equal(t, conn.RemoteAddr().String(), "10.0.0.9:1234")
+	assert.Equal(t, conn.RemoteIP().String(), "10.0.0.9")
+	assert.Equal(t, conn.Conn().RemoteAddr().String(), "10.0.0.9:1234")
+
+	conn = Req(t).RemoteAddr("::1").Conn()
+	assert.Equal(t, conn.RemoteIP().String(), "::1")
+}
+
+func Test_Conn_TLS(t *testing.T) {
+	conn := Req(t).Host("api.test").TLS().Conn()
+	assert.True(t, conn.IsTLS())
+	assert.Equal(t, conn.TLSConnectionState().ServerName, "api.test")
+	assert.Equal(t, conn.TLSConnectionState().Version, uint16(tls.VersionTLS13))
+}
+
+func Test_Conn_IdAndTime(t *testing.T) {
+	at := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	conn := Req(t).ConnID(0).ConnTime(at).Conn()
+	assert.Equal(t, conn.ConnID(), uint64(0))
+	assert.True(t, conn.ConnTime().Equal(at))
+
+	conn = Req(t).ConnID(99).Conn()
+	assert.Equal(t, conn.ConnID(), uint64(99))
+}
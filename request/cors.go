@@ -0,0 +1,112 @@
+package request
+
+// Helpers for testing CORS:
+//
+//   Req(t).Preflight("https://app.goblgobl.com", "PUT", "Content-Type").Options(handler).
+//     ExpectAllowOrigin("https://app.goblgobl.com").
+//     ExpectAllowMethods("PUT").
+//     ExpectAllowHeaders("Content-Type")
+
+import (
+	"strconv"
+	"strings"
+
+	"src.goblgobl.com/tests/assert"
+)
+
+// Turns the request into a CORS preflight: an OPTIONS with the
+// Origin and Access-Control-Request-* headers
+func (r RequestBuilder) Preflight(origin string, method string, headers ...string) RequestBuilder {
+	r = r.Method("OPTIONS").
+		Header("Origin", origin).
+		Header("Access-Control-Request-Method", method)
+	if len(headers) > 0 {
+		r = r.Header("Access-Control-Request-Headers", strings.Join(headers, ", "))
+	}
+	return r
+}
+
+// Access-Control-Allow-Origin is exactly origin (a "*" doesn't
+// match, since it doesn't allow credentialed requests)
+func (r response) ExpectAllowOrigin(origin string) response {
+	r.t.Helper()
+	actual := r.Headers["Access-Control-Allow-Origin"]
+	if actual != origin {
+		assert.Fail(r.t, "\nexpected Access-Control-Allow-Origin: %s\n\t got: %s", origin, actual)
+	}
+	return r
+}
+
+// Access-Control-Allow-Origin is "*"
+func (r response) ExpectAllowAnyOrigin() response {
+	r.t.Helper()
+	return r.ExpectAllowOrigin("*")
+}
+
+func (r response) ExpectNoAllowOrigin() response {
+	r.t.Helper()
+	if actual, exists := r.Headers["Access-Control-Allow-Origin"]; exists {
+		assert.Fail(r.t, "\nexpected no Access-Control-Allow-Origin, got: %s", actual)
+	}
+	return r
+}
+
+// Access-Control-Allow-Methods contains each of the methods
+func (r response) ExpectAllowMethods(methods ...string) response {
+	r.t.Helper()
+	r.expectHeaderList("Access-Control-Allow-Methods", methods)
+	return r
+}
+
+// Access-Control-Allow-Headers contains each of the headers
+// (case-insensitive)
+func (r response) ExpectAllowHeaders(headers ...string) response {
+	r.t.Helper()
+	r.expectHeaderList("Access-Control-Allow-Headers", headers)
+	return r
+}
+
+// Access-Control-Expose-Headers contains each of the headers
+// (case-insensitive)
+func (r response) ExpectExposeHeaders(headers ...string) response {
+	r.t.Helper()
+	r.expectHeaderList("Access-Control-Expose-Headers", headers)
+	return r
+}
+
+func (r response) ExpectAllowCredentials() response {
+	r.t.Helper()
+	if actual := r.Headers["Access-Control-Allow-Credentials"]; actual != "true" {
+		assert.Fail(r.t, "\nexpected Access-Control-Allow-Credentials: true\n\t got: %s", actual)
+	}
+	return r
+}
+
+func (r response) ExpectMaxAge(seconds int) response {
+	r.t.Helper()
+	assert.Equal(r.t, r.Headers["Access-Control-Max-Age"], strconv.Itoa(seconds))
+	return r
+}
+
+func (r response) expectHeaderList(name string, expected []string) {
+	r.t.Helper()
+	header := r.Headers[name]
+	if header == "*" {
+		return
+	}
+
+	actual := make(map[string]bool)
+	for _, value := range strings.Split(header, ",") {
+		actual[strings.ToLower(strings.TrimSpace(value))] = true
+	}
+
+	var missing []string
+	for _, value := range expected {
+		if !actual[strings.ToLower(value)] {
+			missing = append(missing, value)
+		}
+	}
+	if len(missing) > 0 {
+		assert.Fail(r.t, "\nexpected %s to contain: %s\n\t got: %s", name, strings.Join(missing, ", "), header)
+	}
+}
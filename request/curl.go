@@ -0,0 +1,112 @@
+package request
+
+// To reproduce a failing test by hand (e.g. against a dev server)
+// the request is rendered as a curl command and the response as
+// a raw HTTP transcript. Both are included in the failure
+// message of ExpectStatus and OK.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The request as a copy-pasteable curl command
+func (r RequestBuilder) Curl() string {
+	method := r.method
+	if method == "" {
+		method = "GET"
+	}
+
+	url := "http://"
+	if r.tls != nil {
+		url = "https://"
+	}
+	if h := r.host; h != "" {
+		url += h
+	} else {
+		url += "test.goblgobl.local"
+	}
+	url += r.path
+	if len(r.query) > 0 {
+		url += "?" + r.query.Encode()
+	}
+
+	var sb strings.Builder
+	sb.WriteString("curl")
+	if method == "HEAD" {
+		sb.WriteString(" --head")
+	} else if method != "GET" || r.body != "" {
+		sb.WriteString(" -X " + method)
+	}
+	sb.WriteString(" " + shellQuote(url))
+
+	keys := make([]string, 0, len(r.headers))
+	for key := range r.headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		sb.WriteString(" \\\n  -H " + shellQuote(key+": "+r.headers[key]))
+	}
+
+	if body := r.body; body != "" {
+		sb.WriteString(" \\\n  --data-raw " + shellQuote(body))
+	}
+	return sb.String()
+}
+
+// The response as a raw HTTP transcript (with the body decoded
+// according to its Content-Encoding)
+func (r response) Dump() string {
+	header := r.rawHeader
+	if header == "" {
+		// not generated from a fasthttp response
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "HTTP/1.1 %d\r\n", r.Status)
+		keys := make([]string, 0, len(r.Headers))
+		for key := range r.Headers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&sb, "%s: %s\r\n", key, r.Headers[key])
+		}
+		sb.WriteString("\r\n")
+		header = sb.String()
+	} else if r.Encoding != "" && len(r.Raw) > 0 {
+		header = decodedHeader(header, len(r.Bytes))
+	}
+	return header + r.Body
+}
+
+// The body is dumped decoded, so the headers describing the
+// encoded body are replaced to keep the transcript consistent
+func decodedHeader(header string, length int) string {
+	lines := strings.Split(strings.TrimSuffix(header, "\r\n\r\n"), "\r\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		name, _, _ := strings.Cut(line, ":")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "content-encoding", "content-length":
+			continue
+		}
+		kept = append(kept, line)
+	}
+	kept = append(kept, "Content-Length: "+strconv.Itoa(length))
+	return strings.Join(kept, "\r\n") + "\r\n\r\n"
+}
+
+// Extra context for a failure message
+func (r response) details() string {
+	details := "\n\nresponse:\n" + r.Dump()
+	if r.curl != "" {
+		details = "\n\nrequest:\n" + r.curl + details
+	}
+	return details
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,74 @@
+package request
+
+// Compressed responses are decoded by Res, so that Body/Bytes/Json
+// are always the plain content. The bytes as they were written
+// are available in response.Raw
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+)
+
+func (r RequestBuilder) AcceptEncoding(encodings ...string) RequestBuilder {
+	return r.Header("Accept-Encoding", strings.Join(encodings, ", "))
+}
+
+// The response's Content-Encoding, use "" for none
+func (r response) ExpectEncoding(expected string) response {
+	r.t.Helper()
+	if r.Encoding != expected {
+		assert.Fail(r.t, "\nexpected content encoding: %s\n\t\t got: %s", expected, r.Encoding)
+	}
+	return r
+}
+
+// Decodes the body according to the Content-Encoding header,
+// which can list multiple encodings (applied in order)
+func decodeBody(body []byte, contentEncoding string) ([]byte, error) {
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+		switch encoding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			body, err = decode(body, fasthttp.WriteGunzip)
+		case "deflate":
+			body, err = decode(body, fasthttp.WriteInflate)
+		case "br":
+			body, err = decode(body, fasthttp.WriteUnbrotli)
+		case "zstd":
+			body, err = unzstd(body)
+		default:
+			err = fmt.Errorf("unsupported Content-Encoding: %s", encoding)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+func decode(body []byte, fn func(io.Writer, []byte) (int, error)) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := fn(&buf, body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func unzstd(body []byte) ([]byte, error) {
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	return decoder.DecodeAll(body, nil)
+}
@@ -0,0 +1,243 @@
+package request
+
+// When GOBL_TEST_HAR is set (e.g. GOBL_TEST_HAR=out.har), every
+// request made through RequestBuilder.Request / RequestBuilderT.Request
+// is recorded, along with its response, and written to a HAR 1.2
+// file once the package's tests are done. This needs the package's
+// TestMain to run the tests through RunWithHAR:
+//
+//   func TestMain(m *testing.M) {
+//     os.Exit(request.RunWithHAR(m))
+//   }
+//
+// Without it, nothing is recorded and the first test to make a
+// request fails, saying so.
+//
+// A relative path ends up in the directory of each tested package.
+// An absolute one gets the package's name added (out.har becomes
+// out.users.har), since go test ./... runs packages in parallel.
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+)
+
+var har = &harRecorder{path: harPath(os.Getenv("GOBL_TEST_HAR"))}
+
+// Runs the tests and, when GOBL_TEST_HAR is set, writes the
+// recorded requests. Returns m.Run's exit code.
+func RunWithHAR(m *testing.M) int {
+	har.running.Store(true)
+	code := m.Run()
+	har.running.Store(false)
+	if har.path != "" {
+		if err := har.write(har.path); err != nil {
+			os.Stderr.WriteString("failed to write HAR file " + har.path + ": " + err.Error() + "\n")
+		}
+	}
+	return code
+}
+
+// Writes all the requests recorded so far (see RunWithHAR) to path
+func WriteHAR(path string) error {
+	return har.write(path)
+}
+
+func harPath(path string) string {
+	if path == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	// the test binary is named after the package, e.g. users.test
+	pkg := filepath.Base(os.Args[0])
+	pkg = strings.TrimSuffix(strings.TrimSuffix(pkg, ".exe"), ".test")
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + pkg + ext
+}
+
+type harRecorder struct {
+	path    string
+	running atomic.Bool
+	misused sync.Once
+	lock    sync.Mutex
+	entries []harEntry
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harEntry struct {
+	Started  string      `json:"startedDateTime"`
+	Time     float64     `json:"time"`
+	Request  harRequest  `json:"request"`
+	Response harResponse `json:"response"`
+	Cache    struct{}    `json:"cache"`
+	Timings  harTimings  `json:"timings"`
+	Comment  string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func (h *harRecorder) enabled() bool {
+	return h.path != ""
+}
+
+func (h *harRecorder) record(t *testing.T, conn *fasthttp.RequestCtx, res response, started time.Time, elapsed time.Duration) {
+	if !h.running.Load() {
+		// a stderr warning would be hidden by go test ./... for
+		// packages which pass
+		h.misused.Do(func() {
+			t.Errorf("GOBL_TEST_HAR is set, but this package's TestMain doesn't use request.RunWithHAR, so no HAR file would be written")
+		})
+		return
+	}
+
+	req := &conn.Request
+	ms := float64(elapsed.Microseconds()) / 1000
+
+	entry := harEntry{
+		Started: started.Format(time.RFC3339Nano),
+		Time:    ms,
+		Comment: t.Name(),
+		Timings: harTimings{Wait: ms},
+		Request: harRequest{
+			Method:      string(req.Header.Method()),
+			URL:         req.URI().String(),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(req.Header.VisitAll),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(req.Body()),
+		},
+		Response: harResponse{
+			Status:      res.Status,
+			StatusText:  fasthttp.StatusMessage(res.Status),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(conn.Response.Header.VisitAll),
+			RedirectURL: res.Headers["Location"],
+			HeadersSize: -1,
+			BodySize:    len(res.Raw),
+			Content: harContent{
+				Size:     len(res.Bytes),
+				MimeType: string(conn.Response.Header.ContentType()),
+			},
+		},
+	}
+
+	req.URI().QueryArgs().VisitAll(func(key []byte, value []byte) {
+		entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{string(key), string(value)})
+	})
+
+	if body := req.Body(); len(body) > 0 {
+		entry.Request.PostData = &harPostData{
+			MimeType: string(req.Header.ContentType()),
+			Text:     string(body),
+		}
+	}
+
+	if body := res.Bytes; utf8.Valid(body) {
+		entry.Response.Content.Text = string(body)
+	} else {
+		entry.Response.Content.Text = base64.StdEncoding.EncodeToString(body)
+		entry.Response.Content.Encoding = "base64"
+	}
+
+	h.lock.Lock()
+	h.entries = append(h.entries, entry)
+	h.lock.Unlock()
+}
+
+func (h *harRecorder) write(path string) error {
+	h.lock.Lock()
+	entries := h.entries[:len(h.entries):len(h.entries)]
+	h.lock.Unlock()
+	if entries == nil {
+		entries = []harEntry{}
+	}
+
+	data, err := json.MarshalIndent(map[string]any{
+		"log": map[string]any{
+			"version": "1.2",
+			"creator": map[string]string{
+				"name":    "src.goblgobl.com/tests",
+				"version": "1",
+			},
+			"entries": entries,
+		},
+	}, "", " ")
+	if err != nil {
+		return err
+	}
+
+	// write + rename so that a reader never sees a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func harHeaders(visitAll func(func(key []byte, value []byte))) []harNameValue {
+	headers := []harNameValue{}
+	visitAll(func(key []byte, value []byte) {
+		headers = append(headers, harNameValue{string(key), string(value)})
+	})
+	sort.SliceStable(headers, func(i, j int) bool {
+		return headers[i].Name < headers[j].Name
+	})
+	return headers
+}
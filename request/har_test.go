@@ -0,0 +1,63 @@
+package request
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_HAR_Record_And_Write(t *testing.T) {
+	h := &harRecorder{path: "unused"}
+	h.running.Store(true)
+
+	conn := Req(t).Path("/users").Query("id", "1").Conn()
+	conn.SetStatusCode(201)
+	conn.SetBodyString(`{"id":1}`)
+	h.record(t, conn, Res(t, conn), time.Now(), time.Millisecond)
+
+	path := filepath.Join(t.TempDir(), "out.har")
+	assert.Nil(t, h.write(path))
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	var file struct {
+		Log struct {
+			Version string     `json:"version"`
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	assert.Nil(t, json.Unmarshal(data, &file))
+	assert.Equal(t, file.Log.Version, "1.2")
+	assert.Equal(t, len(file.Log.Entries), 1)
+
+	entry := file.Log.Entries[0]
+	assert.Equal(t, entry.Comment, t.Name())
+	assert.Equal(t, entry.Request.URL, "http://test.goblgobl.local/users?id=1")
+	assert.Equal(t, entry.Response.Status, 201)
+	assert.Equal(t, entry.Response.Content.Text, `{"id":1}`)
+}
+
+func Test_HAR_Path(t *testing.T) {
+	assert.Equal(t, harPath(""), "")
+	assert.Equal(t, harPath("out.har"), "out.har")
+
+	abs := filepath.Join(t.TempDir(), "out.har")
+	assert.Equal(t, filepath.Dir(harPath(abs)), filepath.Dir(abs))
+	assert.True(t, harPath(abs) != abs)
+}
+
+func Test_HAR_NotRunning_Records_Nothing(t *testing.T) {
+	h := &harRecorder{path: "unused"}
+	// as if the misuse was already reported, so this test passes
+	h.misused.Do(func() {})
+
+	conn := Req(t).Conn()
+	conn.SetStatusCode(fasthttp.StatusOK)
+	h.record(t, conn, Res(t, conn), time.Now(), time.Millisecond)
+	assert.Equal(t, len(h.entries), 0)
+}
@@ -0,0 +1,116 @@
+package request
+
+// Lets tests run a handler through the same middleware stack
+// as production:
+//
+//   Req(t).Use(auth, loadProject).Get(handler)
+//   ReqT(t, env).Use(auth, loadProject).Get(handler)
+//
+// Middlewares run in the order they're given (the first one is
+// the outermost). When a middleware responds without calling
+// next, response.ShortCircuitedBy is set to its name.
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/utils/http"
+)
+
+type Middleware func(next Handler) Handler
+
+type HandlerT[T any] func(*fasthttp.RequestCtx, T) (http.Response, error)
+
+type MiddlewareT[T any] func(next HandlerT[T]) HandlerT[T]
+
+// Composes the middlewares into a single middleware, the first
+// being the outermost
+func Chain(middlewares ...Middleware) Middleware {
+	return func(handler Handler) Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			handler = middlewares[i](handler)
+		}
+		return handler
+	}
+}
+
+func ChainT[T any](middlewares ...MiddlewareT[T]) MiddlewareT[T] {
+	return func(handler HandlerT[T]) HandlerT[T] {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			handler = middlewares[i](handler)
+		}
+		return handler
+	}
+}
+
+// Tracks how deep into a chain of middlewares a request got
+type chainTracker struct {
+	names []string
+	depth int
+}
+
+func newChainTracker(names []string) *chainTracker {
+	return &chainTracker{names: names, depth: -1}
+}
+
+func (c *chainTracker) enter(depth int) {
+	if depth > c.depth {
+		c.depth = depth
+	}
+}
+
+// The name of the middleware which didn't call next, or ""
+// if the handler was reached
+func (c *chainTracker) shortCircuitedBy() string {
+	if c.depth >= len(c.names) || c.depth < 0 {
+		return ""
+	}
+	return c.names[c.depth]
+}
+
+func trackedChain(handler Handler, middlewares []Middleware, tracker *chainTracker) Handler {
+	l := len(middlewares)
+	h := func(conn *fasthttp.RequestCtx) {
+		tracker.enter(l)
+		handler(conn)
+	}
+	for i := l - 1; i >= 0; i-- {
+		i, next := i, middlewares[i](h)
+		h = func(conn *fasthttp.RequestCtx) {
+			tracker.enter(i)
+			next(conn)
+		}
+	}
+	return h
+}
+
+func trackedChainT[T any](handler HandlerT[T], middlewares []MiddlewareT[T], tracker *chainTracker) HandlerT[T] {
+	l := len(middlewares)
+	h := func(conn *fasthttp.RequestCtx, env T) (http.Response, error) {
+		tracker.enter(l)
+		return handler(conn, env)
+	}
+	for i := l - 1; i >= 0; i-- {
+		i, next := i, middlewares[i](h)
+		h = func(conn *fasthttp.RequestCtx, env T) (http.Response, error) {
+			tracker.enter(i)
+			return next(conn, env)
+		}
+	}
+	return h
+}
+
+// A best-effort name for a middleware, e.g. "auth.RequireUser"
+func middlewareName(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "?"
+	}
+	name := f.Name()
+	if i := strings.LastIndexByte(name, '/'); i != -1 {
+		name = name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,523 @@
+package request
+
+// Validates requests made by a builder with a Spec against an
+// OpenAPI 3 (json) document:
+//
+//   var spec = request.MustLoadSpec("../docs/openapi.json")
+//
+//   func Test_GetUser(t *testing.T) {
+//     request.Req(t).Spec(spec).Path("/users/1").Get(getUser).OK()
+//   }
+//
+//   func TestMain(m *testing.M) {
+//     code := m.Run()
+//     for _, op := range spec.Unused() {
+//       fmt.Println("untested operation:", op)
+//     }
+//     os.Exit(code)
+//   }
+//
+// Requests made without a Spec (health checks, internal routes,
+// ...) aren't checked.
+//
+// The operation is found by method and path template. The
+// response's status, headers and json body must match what the
+// operation documents. The request (path/query/header params and
+// json body) is validated too, but since tests often send invalid
+// requests on purpose, request violations are only reported when
+// the handler accepted the request (a status < 400).
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/tests/schema"
+)
+
+type Spec struct {
+	doc        *schema.Schema
+	basePath   string
+	operations []*operation
+	lock       sync.Mutex
+	hits       map[*operation]int
+}
+
+type operation struct {
+	method   string
+	template string
+	segments []string
+	// json pointer to the operation, e.g. #/paths/~1users~1{id}/get
+	pointer string
+	node    map[string]any
+	params  []parameter
+}
+
+type parameter struct {
+	node    map[string]any
+	pointer string
+}
+
+// Checks the request, and its response, against the spec (nil
+// disables the check)
+func (r RequestBuilder) Spec(spec *Spec) RequestBuilder {
+	r.spec = spec
+	return r
+}
+
+// Like LoadSpec, but panics if the spec can't be loaded
+func MustLoadSpec(spec any) *Spec {
+	s, err := LoadSpec(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// The spec can be a path to a json file, raw json ([]byte or string)
+// or a map[string]any
+func LoadSpec(spec any) (*Spec, error) {
+	doc, err := schema.Open(spec)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := doc.Node().(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("openapi spec must be a json object")
+	}
+
+	s := &Spec{doc: doc, hits: make(map[*operation]int)}
+	if servers, ok := root["servers"].([]any); ok && len(servers) > 0 {
+		if server, ok := servers[0].(map[string]any); ok {
+			if u, err := url.Parse(fmt.Sprint(server["url"])); err == nil {
+				s.basePath = strings.TrimSuffix(u.Path, "/")
+			}
+		}
+	}
+
+	paths, _ := root["paths"].(map[string]any)
+	for template, p := range paths {
+		pathItem, ok := p.(map[string]any)
+		if !ok {
+			continue
+		}
+		pathPointer := "#/paths/" + escapePointer(template)
+		pathParams := s.parameters(pathItem["parameters"], pathPointer)
+		for method, o := range pathItem {
+			node, ok := o.(map[string]any)
+			if !ok || !isHTTPMethod(method) {
+				continue
+			}
+			pointer := pathPointer + "/" + method
+			s.operations = append(s.operations, &operation{
+				node:     node,
+				pointer:  pointer,
+				template: template,
+				method:   strings.ToUpper(method),
+				segments: splitPath(template),
+				params:   mergeParameters(pathParams, s.parameters(node["parameters"], pointer)),
+			})
+		}
+	}
+
+	sort.Slice(s.operations, func(i, j int) bool {
+		a, b := s.operations[i], s.operations[j]
+		if a.template == b.template {
+			return a.method < b.method
+		}
+		return a.template < b.template
+	})
+	return s, nil
+}
+
+// Operations (e.g. "GET /users/{id}") which no request has hit
+func (s *Spec) Unused() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var unused []string
+	for _, op := range s.operations {
+		if s.hits[op] == 0 {
+			unused = append(unused, op.method+" "+op.template)
+		}
+	}
+	return unused
+}
+
+func (s *Spec) check(t *testing.T, conn *fasthttp.RequestCtx, res response) {
+	t.Helper()
+	method := string(conn.Method())
+	path := string(conn.Path())
+	if s.basePath != "" {
+		path = strings.TrimPrefix(path, s.basePath)
+	}
+
+	op, params, methodMismatch := s.find(method, path)
+	if op == nil {
+		// probably testing the router itself
+		if res.Status == 404 || res.Status == 405 {
+			return
+		}
+		if methodMismatch {
+			assert.Fail(t, "openapi: %s is not documented for %s", method, path)
+		} else {
+			assert.Fail(t, "openapi: no operation for %s %s", method, path)
+		}
+		return
+	}
+
+	s.lock.Lock()
+	s.hits[op]++
+	s.lock.Unlock()
+
+	var violations []string
+	if res.Status < 400 {
+		for _, v := range s.validateRequest(op, conn, params) {
+			violations = append(violations, "request "+v)
+		}
+	}
+	for _, v := range s.validateResponse(op, res) {
+		violations = append(violations, "response "+v)
+	}
+
+	if len(violations) > 0 {
+		assert.Fail(t, "\nopenapi: %s %s (%s) does not match the spec:\n  %s%s", method, path, op.template, strings.Join(violations, "\n  "), res.details())
+	}
+}
+
+// Finds the operation for the method+path, preferring templates
+// with more literal segments (/users/me over /users/{id})
+func (s *Spec) find(method string, path string) (*operation, map[string]string, bool) {
+	segments := splitPath(path)
+
+	var best *operation
+	var bestParams map[string]string
+	bestLiterals := -1
+	methodMismatch := false
+
+	for _, op := range s.operations {
+		if len(op.segments) != len(segments) {
+			continue
+		}
+		params, literals, ok := matchSegments(op.segments, segments)
+		if !ok {
+			continue
+		}
+		if op.method != method {
+			methodMismatch = true
+			continue
+		}
+		if literals > bestLiterals {
+			best, bestParams, bestLiterals = op, params, literals
+		}
+	}
+	return best, bestParams, methodMismatch
+}
+
+func matchSegments(template []string, actual []string) (map[string]string, int, bool) {
+	literals := 0
+	params := make(map[string]string)
+	for i, t := range template {
+		a := actual[i]
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if a == "" {
+				return nil, 0, false
+			}
+			if unescaped, err := url.PathUnescape(a); err == nil {
+				a = unescaped
+			}
+			params[t[1:len(t)-1]] = a
+			continue
+		}
+		if t != a {
+			return nil, 0, false
+		}
+		literals++
+	}
+	return params, literals, true
+}
+
+func (s *Spec) validateRequest(op *operation, conn *fasthttp.RequestCtx, pathParams map[string]string) []string {
+	var violations []string
+	for _, p := range op.params {
+		param := p.node
+		name, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		required, _ := param["required"].(bool)
+
+		var value string
+		var exists bool
+		switch in {
+		case "path":
+			value, exists = pathParams[name]
+			required = true
+		case "query":
+			var v []byte
+			if v = conn.QueryArgs().Peek(name); v != nil {
+				value, exists = string(v), true
+			}
+		case "header":
+			var v []byte
+			if v = conn.Request.Header.Peek(name); v != nil {
+				value, exists = string(v), true
+			}
+		default:
+			continue
+		}
+
+		label := in + " parameter " + name
+		if !exists {
+			if required {
+				violations = append(violations, label+": is required")
+			}
+			continue
+		}
+		if node, ok := param["schema"]; ok {
+			violations = append(violations, s.validate(label, p.pointer+"/schema", coerce(s.doc, value, node))...)
+		}
+	}
+
+	body, pointer, required := s.requestBody(op, string(conn.Request.Header.ContentType()))
+	raw := conn.Request.Body()
+	if len(raw) == 0 {
+		if required {
+			violations = append(violations, "body: is required")
+		}
+	} else if body != nil {
+		if !json.Valid(raw) {
+			violations = append(violations, "body: is not valid json")
+		} else {
+			violations = append(violations, s.validate("body", pointer, json.RawMessage(raw))...)
+		}
+	}
+	return violations
+}
+
+func (s *Spec) requestBody(op *operation, contentType string) (any, string, bool) {
+	pointer := op.pointer + "/requestBody"
+	node := op.node["requestBody"]
+	if ref, ok := refOf(node); ok {
+		sub, err := s.doc.Ref(ref)
+		if err != nil {
+			return nil, "", false
+		}
+		node, pointer = sub.Node(), ref
+	}
+	requestBody, ok := node.(map[string]any)
+	if !ok {
+		return nil, "", false
+	}
+	required, _ := requestBody["required"].(bool)
+	content, _ := requestBody["content"].(map[string]any)
+	mediaType, schemaNode := jsonContent(content, contentType)
+	if schemaNode == nil {
+		return nil, "", required
+	}
+	return schemaNode, pointer + "/content/" + escapePointer(mediaType) + "/schema", required
+}
+
+func (s *Spec) validateResponse(op *operation, res response) []string {
+	responses, _ := op.node["responses"].(map[string]any)
+	status := strconv.Itoa(res.Status)
+
+	key := status
+	node, exists := responses[key]
+	if !exists {
+		key = status[:1] + "XX"
+		if node, exists = responses[key]; !exists {
+			key = "default"
+			if node, exists = responses[key]; !exists {
+				return []string{fmt.Sprintf("status: %d is not documented", res.Status)}
+			}
+		}
+	}
+
+	pointer := op.pointer + "/responses/" + key
+	if ref, ok := refOf(node); ok {
+		sub, err := s.doc.Ref(ref)
+		if err != nil {
+			return []string{err.Error()}
+		}
+		node, pointer = sub.Node(), ref
+	}
+	spec, _ := node.(map[string]any)
+
+	var violations []string
+	headers, _ := spec["headers"].(map[string]any)
+	for name, h := range headers {
+		headerPointer := pointer + "/headers/" + escapePointer(name)
+		if ref, ok := refOf(h); ok {
+			sub, err := s.doc.Ref(ref)
+			if err != nil {
+				violations = append(violations, err.Error())
+				continue
+			}
+			h, headerPointer = sub.Node(), ref
+		}
+		header, _ := h.(map[string]any)
+		value, exists := lookupHeader(res.Headers, name)
+		if !exists {
+			if required, _ := header["required"].(bool); required {
+				violations = append(violations, "header "+name+": is required")
+			}
+			continue
+		}
+		if node, ok := header["schema"]; ok {
+			violations = append(violations, s.validate("header "+name, headerPointer+"/schema", coerce(s.doc, value, node))...)
+		}
+	}
+
+	content, _ := spec["content"].(map[string]any)
+	mediaType, schemaNode := jsonContent(content, res.Headers["Content-Type"])
+	if schemaNode != nil && len(res.Bytes) > 0 {
+		if !json.Valid(res.Bytes) {
+			violations = append(violations, "body: is not valid json")
+		} else {
+			schemaPointer := pointer + "/content/" + escapePointer(mediaType) + "/schema"
+			violations = append(violations, s.validate("body", schemaPointer, json.RawMessage(res.Bytes))...)
+		}
+	}
+	return violations
+}
+
+// Validates the value against the schema at pointer
+func (s *Spec) validate(label string, pointer string, value any) []string {
+	sub, err := s.doc.Ref(pointer)
+	if err != nil {
+		return []string{label + ": " + err.Error()}
+	}
+	violations := sub.Validate(value)
+	formatted := make([]string, len(violations))
+	for i, v := range violations {
+		if v.Path == "" {
+			formatted[i] = fmt.Sprintf("%s: %s (%s)", label, v.Message, v.SchemaPath)
+		} else {
+			formatted[i] = label + " " + v.String()
+		}
+	}
+	return formatted
+}
+
+func (s *Spec) parameters(node any, pointer string) []parameter {
+	list, _ := node.([]any)
+	params := make([]parameter, 0, len(list))
+	for i, p := range list {
+		paramPointer := pointer + "/parameters/" + strconv.Itoa(i)
+		if ref, ok := refOf(p); ok {
+			sub, err := s.doc.Ref(ref)
+			if err != nil {
+				continue
+			}
+			p, paramPointer = sub.Node(), ref
+		}
+		if param, ok := p.(map[string]any); ok {
+			params = append(params, parameter{node: param, pointer: paramPointer})
+		}
+	}
+	return params
+}
+
+// operation parameters override path parameters with the same name+in
+func mergeParameters(pathParams []parameter, opParams []parameter) []parameter {
+	merged := append([]parameter{}, opParams...)
+	for _, p := range pathParams {
+		overridden := false
+		for _, o := range opParams {
+			if o.node["name"] == p.node["name"] && o.node["in"] == p.node["in"] {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// Finds the json media type (and its schema) matching contentType
+func jsonContent(content map[string]any, contentType string) (string, any) {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+
+	candidates := []string{contentType, "application/json", "*/*"}
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if media, ok := content[candidate].(map[string]any); ok {
+			if s, exists := media["schema"]; exists {
+				return candidate, s
+			}
+		}
+	}
+	return "", nil
+}
+
+// Parameter and header values are strings, convert them based on
+// the schema's type so that they can be validated
+func coerce(doc *schema.Schema, value string, node any) any {
+	if ref, ok := refOf(node); ok {
+		if sub, err := doc.Ref(ref); err == nil {
+			node = sub.Node()
+		}
+	}
+	s, _ := node.(map[string]any)
+	switch s["type"] {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case "array":
+		parts := strings.Split(value, ",")
+		values := make([]any, len(parts))
+		for i, part := range parts {
+			values[i] = coerce(doc, part, s["items"])
+		}
+		return values
+	}
+	return value
+}
+
+func refOf(node any) (string, bool) {
+	if m, ok := node.(map[string]any); ok {
+		ref, ok := m["$ref"].(string)
+		return ref, ok
+	}
+	return "", false
+}
+
+func lookupHeader(headers map[string]string, name string) (string, bool) {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func isHTTPMethod(method string) bool {
+	switch method {
+	case "get", "put", "post", "delete", "options", "head", "patch", "trace":
+		return true
+	}
+	return false
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
@@ -2,11 +2,15 @@ package request
 
 import (
 	"crypto/sha256"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"src.goblgobl.com/tests"
 	"src.goblgobl.com/tests/assert"
 	"src.goblgobl.com/utils/http"
 	"src.goblgobl.com/utils/json"
@@ -45,6 +49,22 @@ type RequestBuilder struct {
 	query      url.Values
 	headers    map[string]string
 	userValues map[string]any
+	// see middleware.go
+	middlewares     []Middleware
+	middlewareNames []string
+	// see stream.go
+	streamTimeout time.Duration
+	// see conn.go
+	tls        *tls.ConnectionState
+	connID     *uint64
+	connTime   time.Time
+	remoteAddr net.Addr
+	// see CaptureLogs
+	captureLogs bool
+	// see router.go
+	router Handler
+	// see openapi.go
+	spec *Spec
 }
 
 func (r RequestBuilder) Path(path string) RequestBuilder {
@@ -112,41 +132,123 @@ func (r RequestBuilder) Host(host string) RequestBuilder {
 	return r
 }
 
-func (r RequestBuilder) Get(handler Handler) response {
-	return r.Method("GET").Request(handler)
+// Runs the handler through the middlewares, in order
+func (r RequestBuilder) Use(middlewares ...Middleware) RequestBuilder {
+	for _, mw := range middlewares {
+		r = r.UseNamed(middlewareName(mw), mw)
+	}
+	return r
+}
+
+// Same as Use, but with an explicit name for the middleware
+// (as used by response.ExpectShortCircuitedBy)
+func (r RequestBuilder) UseNamed(name string, middleware Middleware) RequestBuilder {
+	r.middlewares = append(r.middlewares[:len(r.middlewares):len(r.middlewares)], middleware)
+	r.middlewareNames = append(r.middlewareNames[:len(r.middlewareNames):len(r.middlewareNames)], name)
+	return r
+}
+
+func (r RequestBuilder) Get(handler ...Handler) response {
+	return r.Method("GET").Request(optionalHandler(handler))
 }
 
-func (r RequestBuilder) Post(handler Handler) response {
-	return r.Method("POST").Request(handler)
+func (r RequestBuilder) Post(handler ...Handler) response {
+	return r.Method("POST").Request(optionalHandler(handler))
 }
 
-func (r RequestBuilder) Put(handler Handler) response {
-	return r.Method("PUT").Request(handler)
+func (r RequestBuilder) Put(handler ...Handler) response {
+	return r.Method("PUT").Request(optionalHandler(handler))
 }
 
-func (r RequestBuilder) Delete(handler Handler) response {
-	return r.Method("DELETE").Request(handler)
+func (r RequestBuilder) Delete(handler ...Handler) response {
+	return r.Method("DELETE").Request(optionalHandler(handler))
 }
 
+func (r RequestBuilder) Patch(handler ...Handler) response {
+	return r.Method("PATCH").Request(optionalHandler(handler))
+}
+
+// The response's body is dropped (like the server would) but its
+// ContentLength is kept
+func (r RequestBuilder) Head(handler ...Handler) response {
+	return r.Method("HEAD").Request(optionalHandler(handler))
+}
+
+func (r RequestBuilder) Options(handler ...Handler) response {
+	return r.Method("OPTIONS").Request(optionalHandler(handler))
+}
+
+// When handler is nil, the request is dispatched through the
+// builder's router (see Router)
 func (r RequestBuilder) Request(handler Handler) response {
+	r.t.Helper()
+	if handler == nil {
+		if handler = r.router; handler == nil {
+			assert.Fail(r.t, "no handler given (only builders created by request.Router can omit it)")
+		}
+	}
+
 	conn := r.Conn()
-	handler(conn)
-	return Res(r.t, conn)
+	tracker := newChainTracker(r.middlewareNames)
+	handler = trackedChain(handler, r.middlewares, tracker)
+	started := time.Now()
+	var logs *tests.LogRecorder
+	if r.captureLogs {
+		logs = tests.CaptureLogs(r.t, func() {
+			handler(conn)
+		})
+	} else {
+		handler(conn)
+	}
+	res := newResponse(r.t, conn, r.getStreamTimeout())
+	res.Logs = logs
+	res.ShortCircuitedBy = tracker.shortCircuitedBy()
+	res.curl = r.Curl()
+	if r.spec != nil {
+		r.spec.check(r.t, conn, res)
+	}
+	if har.enabled() {
+		har.record(r.t, conn, res, started, time.Since(started))
+	}
+	return res
+}
+
+func (r RequestBuilder) getStreamTimeout() time.Duration {
+	if t := r.streamTimeout; t > 0 {
+		return t
+	}
+	return StreamTimeout
+}
+
+func optionalHandler(handlers []Handler) Handler {
+	switch len(handlers) {
+	case 0:
+		return nil
+	case 1:
+		return handlers[0]
+	default:
+		panic("a request takes a single handler")
+	}
 }
 
 func (r RequestBuilder) Conn() *fasthttp.RequestCtx {
-	request := new(fasthttp.Request)
+	ctx := new(fasthttp.RequestCtx)
+	r.initConn(ctx)
+
+	request := &ctx.Request
 	if body := r.body; body != "" {
 		request.AppendBodyString(body)
 	}
-	header := new(fasthttp.RequestHeader)
+	header := &request.Header
 	header.SetMethod(r.method)
 	for key, value := range r.headers {
 		header.Add(key, value)
 	}
-	request.Header = *header
 
 	uri := "http://"
+	if r.tls != nil {
+		uri = "https://"
+	}
 	if h := r.host; h != "" {
 		uri += h
 	} else {
@@ -158,10 +260,6 @@ func (r RequestBuilder) Conn() *fasthttp.RequestCtx {
 	}
 	request.SetRequestURI(uri)
 
-	ctx := &fasthttp.RequestCtx{
-		Request: *request,
-	}
-
 	for key, value := range r.userValues {
 		ctx.SetUserValue(key, value)
 	}
@@ -170,12 +268,41 @@ func (r RequestBuilder) Conn() *fasthttp.RequestCtx {
 }
 
 func Res(t *testing.T, conn *fasthttp.RequestCtx) response {
-	res := conn.Response
+	return newResponse(t, conn, StreamTimeout)
+}
+
+func newResponse(t *testing.T, conn *fasthttp.RequestCtx, streamTimeout time.Duration) response {
+	res := &conn.Response
+
+	streamed := res.IsBodyStream()
+	chunks, streamTimedOut := drainStream(res, streamTimeout)
+
+	raw := res.Body()
+	body := raw
+	encoding := string(res.Header.ContentEncoding())
+	if encoding != "" && len(raw) > 0 {
+		var err error
+		if body, err = decodeBody(raw, encoding); err != nil {
+			assert.Fail(t, "failed to decode %s response body: %v", encoding, err)
+		}
+	}
+	// like fasthttp's Response.Write: a (non-streamed) body sets the
+	// Content-Length, for every method. A HEAD's body is then dropped,
+	// but its Content-Length is kept (as is one set by the handler
+	// when it didn't write a body).
+	contentLength := res.Header.ContentLength()
+	if !streamed && (len(raw) > 0 || !conn.IsHead()) {
+		contentLength = len(raw)
+	}
+	if conn.IsHead() {
+		raw, body = nil, nil
+	}
 
-	body := res.Body()
 	// might not be json, just ignore if so, let the test deal with it
 	json, _ := typed.Json(body)
 
+	rawHeader := res.Header.String()
+
 	headers := make(map[string]string)
 	res.Header.VisitAll(func(key []byte, value []byte) {
 		headers[string(key)] = string(value)
@@ -183,6 +310,16 @@ func Res(t *testing.T, conn *fasthttp.RequestCtx) response {
 
 	status := res.StatusCode()
 
+	var events []Event
+	if isEventStream(res.Header.ContentType()) {
+		events = parseEvents(body)
+	}
+
+	var route string
+	if value := conn.UserValue(RouteKey); value != nil {
+		route = fmt.Sprint(value)
+	}
+
 	// if we have a validation error, let's parse them into a lookup
 	// of field => code
 	var validations map[string][]typed.Typed
@@ -195,16 +332,25 @@ func Res(t *testing.T, conn *fasthttp.RequestCtx) response {
 		}
 	}
 
-	return response{
+	r := response{
 		t:             t,
 		Json:          json,
 		Headers:       headers,
+		Raw:           raw,
 		Bytes:         body,
+		Encoding:      encoding,
 		Body:          string(body),
 		Status:        status,
 		Validations:   validations,
-		ContentLength: res.Header.ContentLength(),
+		Route:         route,
+		ContentLength: contentLength,
+		Events:        events,
+		Chunks:        chunks,
 	}
+	r.rawHeader = rawHeader
+	r.Streamed = streamed
+	r.StreamTimedOut = streamTimedOut
+	return r
 }
 
 type response struct {
@@ -217,6 +363,26 @@ type response struct {
 	ContentLength int
 	Headers       map[string]string
 	Validations   map[string][]typed.Typed
+	// the body as written, Bytes/Body/Json are decoded
+	// according to the Content-Encoding (see encoding.go)
+	Raw      []byte
+	Encoding string
+	// see router.go
+	Route string
+	// see stream.go
+	Chunks         []Chunk
+	Events         []Event
+	Streamed       bool
+	StreamTimedOut bool
+	// what was logged while handling the request (nil unless
+	// the builder's CaptureLogs was called)
+	Logs *tests.LogRecorder
+	// name of the middleware which responded without calling
+	// next, "" if the handler was reached
+	ShortCircuitedBy string
+	// see curl.go
+	curl      string
+	rawHeader string
 }
 
 func (r response) SHA256() string {
@@ -272,7 +438,25 @@ func (r response) ExpectInvalid(code ...int) response {
 func (r response) ExpectStatus(expected int) response {
 	r.t.Helper()
 	if r.Status != expected {
-		assert.Fail(r.t, "\nexpected status code: %d\n\t\t got: %d\nbody: %s", expected, r.Status, r.Body)
+		assert.Fail(r.t, "\nexpected status code: %d\n\t\t got: %d%s", expected, r.Status, r.details())
+	}
+	return r
+}
+
+// The handler didn't write a body (e.g. for a 204). Always true
+// for a HEAD, whose body is dropped, see ExpectContentLength
+func (r response) ExpectNoBody() response {
+	r.t.Helper()
+	if len(r.Bytes) != 0 {
+		assert.Fail(r.t, "\nexpected no body, got: %s", r.Body)
+	}
+	return r
+}
+
+func (r response) ExpectContentLength(expected int) response {
+	r.t.Helper()
+	if r.ContentLength != expected {
+		assert.Fail(r.t, "\nexpected content length: %d\n\t\t got: %d", expected, r.ContentLength)
 	}
 	return r
 }
@@ -294,60 +478,76 @@ func (r response) Inspect() response {
 	return r
 }
 
+// Alternating field/expectation pairs, e.g.
+//
+//	ExpectValidation("name", 1001, "items.*.id", 1002)
+//
+// The expectation can be a code, a message (string) or anything
+// that assert.V.Field accepts, like a validation.Invalid or a
+// map with a code and data. A "" field matches fieldless errors.
+// Fields can be nested paths (items.2.name), where * matches any
+// one segment (items.*.name).
 func (r response) ExpectValidation(expected ...any) response {
 	r.t.Helper()
+	r.expectValidation(expected)
+	return r
+}
+
+// Like ExpectValidation, but any other validation error fails
+func (r response) ExpectOnlyValidation(expected ...any) response {
+	r.t.Helper()
+	r.expectValidation(expected).Only()
+	return r
+}
+
+func (r response) expectValidation(expected []any) *assert.V {
+	r.t.Helper()
+	if len(expected)%2 != 0 {
+		r.t.Fatalf("ExpectValidation takes field/expectation pairs, got %d arguments", len(expected))
+	}
+	for i := 0; i < len(expected); i += 2 {
+		if _, ok := expected[i].(string); !ok {
+			r.t.Fatalf("ExpectValidation takes field/expectation pairs, argument %d should be a field (string), got %T", i, expected[i])
+		}
+	}
+
 	r.ExpectStatus(400)
 	r.ExpectCode(2004)
 
-	valid := true
-	lookup := r.Validations
+	v := r.validation()
 	for i := 0; i < len(expected); i += 2 {
-		found := false
 		field := expected[i].(string)
-		expectedCode := expected[i+1].(int)
-		actuals := lookup[field]
-		for _, actual := range actuals {
-			found = true
-			actualCode := actual.Int("code")
-			if expectedCode == actualCode {
-				break
-			}
-			r.t.Errorf("Expect validation code for field '%s' to be %d, got %d", field, expectedCode, actualCode)
-			valid = false
-		}
-
-		if !found {
-			r.t.Errorf("No validation error for field '%s'", field)
-			valid = false
+		switch e := expected[i+1].(type) {
+		case string:
+			v.FieldMessage(field, e)
+		default:
+			v.Field(field, e)
 		}
 	}
-
-	if !valid {
-		r.t.FailNow()
-	}
-
-	return r
+	return v
 }
 
+// Fields can use the same paths/wildcards as ExpectValidation
 func (r response) ExpectNoValidation(fields ...string) response {
 	r.t.Helper()
-	valid := true
-	for _, field := range fields {
-		if invalid, exists := r.Validations[field]; exists {
-			valid = false
-			r.t.Errorf("Did not expect an error for field: '%s', got: '%v'", field, invalid)
-		}
-	}
-	if !valid {
-		r.t.FailNow()
+	if r.Validations != nil {
+		r.validation().FieldsHaveNoErrors(fields...)
 	}
 	return r
 }
 
+func (r response) validation() *assert.V {
+	invalid := r.Json["invalid"]
+	if invalid == nil {
+		invalid = []any{}
+	}
+	return assert.ValidationErrors(r.t, invalid)
+}
+
 func (r response) OK() response {
 	r.t.Helper()
 	if r.Status != 200 && r.Status != 201 && r.Status != 204 {
-		r.t.Errorf("Expect 200/201/204 status code, got: %d\n%s\n%v", r.Status, r.Body, r.Err)
+		r.t.Errorf("Expect 200/201/204 status code, got: %d\n%v%s", r.Status, r.Err, r.details())
 		r.t.FailNow()
 	}
 	return r
@@ -364,3 +564,72 @@ func (r response) Header(name string, expected string) response {
 	assert.Equal(r.t, r.Headers[name], expected)
 	return r
 }
+
+func (r response) ExpectShortCircuitedBy(name string) response {
+	r.t.Helper()
+	if r.ShortCircuitedBy != name {
+		actual := r.ShortCircuitedBy
+		if actual == "" {
+			actual = "(handler was reached)"
+		}
+		assert.Fail(r.t, "\nexpected request to be short-circuited by: %s\n\t\t got: %s\nbody: %s", name, actual, r.Body)
+	}
+	return r
+}
+
+// No middleware short-circuited the request
+func (r response) ExpectHandlerReached() response {
+	r.t.Helper()
+	if name := r.ShortCircuitedBy; name != "" {
+		assert.Fail(r.t, "\nexpected handler to be reached, short-circuited by: %s\nbody: %s", name, r.Body)
+	}
+	return r
+}
+
+// Captures what the handler logs (through log.Out, process-wide)
+// into response.Logs, for ExpectLogged and friends
+func (r RequestBuilder) CaptureLogs() RequestBuilder {
+	r.captureLogs = true
+	return r
+}
+
+// Something was logged at the given level (any level if "") with
+// all of the given fields
+func (r response) ExpectLogged(level string, fields map[string]any) response {
+	r.t.Helper()
+	r.logs().ExpectLog(level, fields)
+	return r
+}
+
+func (r response) ExpectNotLogged(level string, fields map[string]any) response {
+	r.t.Helper()
+	r.logs().ExpectNoLog(level, fields)
+	return r
+}
+
+// The handler's error went through env.ServerError, which
+// responded with a 500 and logged an error (with the optional fields)
+func (r response) ExpectServerErrorLogged(fields ...map[string]any) response {
+	r.t.Helper()
+	r.ExpectStatus(500)
+	var expected map[string]any
+	if len(fields) == 1 {
+		expected = fields[0]
+	}
+	r.logs().ExpectLog("error", expected)
+	return r
+}
+
+func (r response) ExpectNoErrorsLogged() response {
+	r.t.Helper()
+	r.logs().ExpectNoErrors()
+	return r
+}
+
+func (r response) logs() *tests.LogRecorder {
+	r.t.Helper()
+	if r.Logs == nil {
+		assert.Fail(r.t, "response has no captured logs (call CaptureLogs() on the request builder)")
+	}
+	return r.Logs
+}
@@ -15,9 +15,12 @@ package request
 // need to have unique names for each.
 
 import (
+	"crypto/tls"
 	"testing"
+	"time"
 
 	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests"
 	"src.goblgobl.com/utils/http"
 	"src.goblgobl.com/utils/log"
 )
@@ -28,12 +31,58 @@ type Env interface {
 }
 
 func ReqT[T Env](t *testing.T, env T) RequestBuilderT[T] {
-	return RequestBuilderT[T]{env, Req(t)}
+	return RequestBuilderT[T]{
+		env:   env,
+		rb:    Req(t),
+		route: "testing",
+	}
 }
 
 type RequestBuilderT[T Env] struct {
-	env T
-	rb  RequestBuilder
+	env    T
+	rb     RequestBuilder
+	route  string
+	before []func(*fasthttp.RequestCtx, T)
+	after  []func(*fasthttp.RequestCtx, T, http.Response)
+	// see middleware.go
+	middlewares     []MiddlewareT[T]
+	middlewareNames []string
+}
+
+// The route name passed to env.Request (defaults to "testing")
+func (r RequestBuilderT[T]) Route(route string) RequestBuilderT[T] {
+	r.route = route
+	return r
+}
+
+// Called (in the order they were added) after env.Request and
+// before the handler, much like a middleware would
+func (r RequestBuilderT[T]) Before(fn func(*fasthttp.RequestCtx, T)) RequestBuilderT[T] {
+	r.before = append(r.before[:len(r.before):len(r.before)], fn)
+	return r
+}
+
+// Called (in the order they were added) after the response, or
+// the env.ServerError response, has been written
+func (r RequestBuilderT[T]) After(fn func(*fasthttp.RequestCtx, T, http.Response)) RequestBuilderT[T] {
+	r.after = append(r.after[:len(r.after):len(r.after)], fn)
+	return r
+}
+
+// Runs the handler through the middlewares, in order
+func (r RequestBuilderT[T]) Use(middlewares ...MiddlewareT[T]) RequestBuilderT[T] {
+	for _, mw := range middlewares {
+		r = r.UseNamed(middlewareName(mw), mw)
+	}
+	return r
+}
+
+// Same as Use, but with an explicit name for the middleware
+// (as used by response.ExpectShortCircuitedBy)
+func (r RequestBuilderT[T]) UseNamed(name string, middleware MiddlewareT[T]) RequestBuilderT[T] {
+	r.middlewares = append(r.middlewares[:len(r.middlewares):len(r.middlewares)], middleware)
+	r.middlewareNames = append(r.middlewareNames[:len(r.middlewareNames):len(r.middlewareNames)], name)
+	return r
 }
 
 func (r RequestBuilderT[T]) Path(path string) RequestBuilderT[T] {
@@ -86,6 +135,40 @@ func (r RequestBuilderT[T]) Host(host string) RequestBuilderT[T] {
 	return r
 }
 
+func (r RequestBuilderT[T]) RemoteAddr(addr string) RequestBuilderT[T] {
+	r.rb = r.rb.RemoteAddr(addr)
+	return r
+}
+
+func (r RequestBuilderT[T]) TLS(state ...tls.ConnectionState) RequestBuilderT[T] {
+	r.rb = r.rb.TLS(state...)
+	return r
+}
+
+func (r RequestBuilderT[T]) ConnID(id uint64) RequestBuilderT[T] {
+	r.rb = r.rb.ConnID(id)
+	return r
+}
+
+func (r RequestBuilderT[T]) ConnTime(connTime time.Time) RequestBuilderT[T] {
+	r.rb = r.rb.ConnTime(connTime)
+	return r
+}
+
+func (r RequestBuilderT[T]) AcceptEncoding(encodings ...string) RequestBuilderT[T] {
+	r.rb = r.rb.AcceptEncoding(encodings...)
+	return r
+}
+
+func (r RequestBuilderT[T]) StreamTimeout(timeout time.Duration) RequestBuilderT[T] {
+	r.rb = r.rb.StreamTimeout(timeout)
+	return r
+}
+
+func (r RequestBuilderT[T]) Curl() string {
+	return r.rb.Curl()
+}
+
 func (r RequestBuilderT[T]) Get(handler func(*fasthttp.RequestCtx, T) (http.Response, error)) response {
 	return r.Method("GET").Request(handler)
 }
@@ -102,18 +185,91 @@ func (r RequestBuilderT[T]) Delete(handler func(*fasthttp.RequestCtx, T) (http.R
 	return r.Method("DELETE").Request(handler)
 }
 
+func (r RequestBuilderT[T]) Patch(handler func(*fasthttp.RequestCtx, T) (http.Response, error)) response {
+	return r.Method("PATCH").Request(handler)
+}
+
+func (r RequestBuilderT[T]) Head(handler func(*fasthttp.RequestCtx, T) (http.Response, error)) response {
+	return r.Method("HEAD").Request(handler)
+}
+
+func (r RequestBuilderT[T]) Options(handler func(*fasthttp.RequestCtx, T) (http.Response, error)) response {
+	return r.Method("OPTIONS").Request(handler)
+}
+
+func (r RequestBuilderT[T]) Spec(spec *Spec) RequestBuilderT[T] {
+	r.rb = r.rb.Spec(spec)
+	return r
+}
+
+// Like RequestBuilder.CaptureLogs. The request's own log (from
+// env.Request's logger) is also recorded; without CaptureLogs it's
+// discarded.
+func (r RequestBuilderT[T]) CaptureLogs() RequestBuilderT[T] {
+	r.rb = r.rb.CaptureLogs()
+	return r
+}
+
+func (r RequestBuilderT[T]) Preflight(origin string, method string, headers ...string) RequestBuilderT[T] {
+	r.rb = r.rb.Preflight(origin, method, headers...)
+	return r
+}
+
 func (r RequestBuilderT[T]) Request(handler func(*fasthttp.RequestCtx, T) (http.Response, error)) response {
 	conn := r.rb.Conn()
-	r.env.Request("testing")
-	res, err := handler(conn, r.env)
-	if err != nil {
-		r.env.ServerError(err, conn).Write(conn, log.Noop{})
+	tracker := newChainTracker(r.middlewareNames)
+	chain := trackedChainT(handler, r.middlewares, tracker)
+
+	var err error
+	var logs *tests.LogRecorder
+	if r.rb.captureLogs {
+		logs = tests.NewLogRecorder(r.rb.t)
+	}
+
+	started := time.Now()
+	run := func() {
+		env := r.env
+		logger := env.Request(r.route)
+		if logs == nil {
+			logger = log.Noop{}
+		}
+		for _, fn := range r.before {
+			fn(conn, env)
+		}
+
+		var res http.Response
+		res, err = chain(conn, env)
+		if err != nil {
+			res = env.ServerError(err, conn)
+		}
+		res.Write(conn, logger)
+		if logs != nil {
+			// straight to this request's recorder, not to log.Out
+			logger.LogTo(logs)
+		}
+
+		for _, fn := range r.after {
+			fn(conn, env, res)
+		}
+	}
+
+	if logs != nil {
+		logs.Capture(run)
 	} else {
-		res.Write(conn, log.Noop{})
+		run()
 	}
 
 	// r2? really? :dealwithit:
-	r2 := Res(r.rb.t, conn)
+	r2 := newResponse(r.rb.t, conn, r.rb.getStreamTimeout())
 	r2.Err = err
+	r2.Logs = logs
+	r2.ShortCircuitedBy = tracker.shortCircuitedBy()
+	r2.curl = r.rb.Curl()
+	if spec := r.rb.spec; spec != nil {
+		spec.check(r.rb.t, conn, r2)
+	}
+	if har.enabled() {
+		har.record(r.rb.t, conn, r2, started, time.Since(started))
+	}
 	return r2
 }
@@ -0,0 +1,29 @@
+package request
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_ContentLength_HeadAndGet(t *testing.T) {
+	handler := func(conn *fasthttp.RequestCtx) {
+		conn.SetBodyString("hello")
+	}
+
+	res := Req(t).Get(handler).ExpectContentLength(5)
+	assert.Equal(t, res.Body, "hello")
+	Req(t).Head(handler).ExpectContentLength(5).ExpectNoBody()
+}
+
+func Test_ContentLength_HeadWithoutBody(t *testing.T) {
+	// a HEAD handler can announce the length without writing the body
+	Req(t).Head(func(conn *fasthttp.RequestCtx) {
+		conn.Response.Header.SetContentLength(42)
+	}).ExpectContentLength(42).ExpectNoBody()
+
+	Req(t).Get(func(conn *fasthttp.RequestCtx) {
+		conn.SetStatusCode(204)
+	}).ExpectContentLength(0).ExpectNoBody()
+}
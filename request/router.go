@@ -0,0 +1,103 @@
+package request
+
+// Dispatches requests through a real route table rather than
+// a specific handler:
+//
+//   rb := request.Router(t, r)  // e.g. a *router.Router from fasthttp/router
+//   rb.Path("/v1/users/123").Get().OK()
+//   rb.Path("/v1/users/123").Post().ExpectMethodNotAllowed("GET")
+//
+// The router belongs to the returned builder (and its copies), so
+// subtests can share it:
+//
+//   t.Run(name, func(t *testing.T) {
+//     rb.Test(t).Path(path).Get().ExpectStatus(status)
+//   })
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+)
+
+// The user value which holds the name (or path) of the matched
+// route, exposed as response.Route. With fasthttp/router, set
+// router.SaveMatchedRoutePath = true and:
+//
+//	request.RouteKey = router.MatchedRoutePathParam
+var RouteKey = "route"
+
+// A new builder which dispatches requests made without a handler
+// through the router. router can be a Handler, a fasthttp.RequestHandler (or any
+// func(*fasthttp.RequestCtx)) or anything with a
+// Handler(*fasthttp.RequestCtx) method, like fasthttp/router.
+func Router(t *testing.T, router any) RequestBuilder {
+	t.Helper()
+	var handler Handler
+	switch r := router.(type) {
+	case Handler:
+		handler = r
+	case fasthttp.RequestHandler:
+		handler = Handler(r)
+	case func(*fasthttp.RequestCtx):
+		handler = r
+	case interface{ Handler(*fasthttp.RequestCtx) }:
+		handler = r.Handler
+	default:
+		t.Fatalf("request.Router: unsupported router type %T", router)
+	}
+
+	rb := Req(t)
+	rb.router = handler
+	return rb
+}
+
+// The same builder (router, headers, middlewares, ...) but
+// reporting to t, e.g. a subtest's. Headers, query and user values
+// are copied, so (parallel) subtests can add their own.
+func (r RequestBuilder) Test(t *testing.T) RequestBuilder {
+	r.t = t
+	r.query = maps.Clone(r.query)
+	r.headers = maps.Clone(r.headers)
+	r.userValues = maps.Clone(r.userValues)
+	return r
+}
+
+func (r response) ExpectRoute(expected string) response {
+	r.t.Helper()
+	if r.Route != expected {
+		assert.Fail(r.t, "\nexpected route: %s\n\t got: %s\nstatus: %d", expected, r.Route, r.Status)
+	}
+	return r
+}
+
+// A 405 and, optionally, that the Allow header lists exactly
+// the given methods
+func (r response) ExpectMethodNotAllowed(allowed ...string) response {
+	r.t.Helper()
+	r.ExpectStatus(405)
+	if len(allowed) == 0 {
+		return r
+	}
+
+	actual := make(map[string]bool)
+	for _, method := range strings.Split(r.Headers["Allow"], ",") {
+		if method = strings.TrimSpace(method); method != "" {
+			actual[strings.ToUpper(method)] = true
+		}
+	}
+
+	valid := len(actual) == len(allowed)
+	for _, method := range allowed {
+		if !actual[strings.ToUpper(method)] {
+			valid = false
+		}
+	}
+	if !valid {
+		assert.Fail(r.t, "\nexpected Allow: %s\n\t got: %s", strings.Join(allowed, ", "), r.Headers["Allow"])
+	}
+	return r
+}
@@ -0,0 +1,38 @@
+package request
+
+import (
+	"encoding/json"
+	"strings"
+
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/tests/schema"
+)
+
+// The json body matches the JSON Schema, given as a
+// map[string]any, raw json, a path to a json file or a
+// *schema.Schema. Every violation is listed.
+func (r response) ExpectSchema(expected any) response {
+	r.t.Helper()
+	s, err := schema.Open(expected)
+	if err != nil {
+		assert.Fail(r.t, "invalid schema: %s", err)
+		return r
+	}
+
+	if !json.Valid(r.Bytes) {
+		assert.Fail(r.t, "\nexpected a json body%s", r.details())
+		return r
+	}
+
+	violations := s.Validate(json.RawMessage(r.Bytes))
+	if len(violations) == 0 {
+		return r
+	}
+
+	lines := make([]string, len(violations))
+	for i, v := range violations {
+		lines[i] = v.String()
+	}
+	assert.Fail(r.t, "\nbody does not match the schema:\n  %s%s", strings.Join(lines, "\n  "), r.details())
+	return r
+}
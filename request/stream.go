@@ -0,0 +1,205 @@
+package request
+
+// Responses with a body stream (ctx.SetBodyStreamWriter, or a
+// streamed http.Response) are drained chunk by chunk, so that
+// tests can look at what was flushed and when. A text/event-stream
+// body is also parsed into a list of Server-Sent Events.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+)
+
+// How long to read a body stream for before giving up on it
+// (e.g. an SSE endpoint which never ends). Can be changed
+// per-request with RequestBuilder.StreamTimeout
+var StreamTimeout = 2 * time.Second
+
+type Chunk struct {
+	Data []byte
+	// time since we started reading the stream
+	Elapsed time.Duration
+}
+
+type Event struct {
+	Id    string
+	Event string
+	Data  string
+	Retry int
+}
+
+func (r RequestBuilder) StreamTimeout(timeout time.Duration) RequestBuilder {
+	r.streamTimeout = timeout
+	return r
+}
+
+// Reads the body stream, if there is one, into chunks and then
+// sets the full content as the response's body
+func drainStream(res *fasthttp.Response, timeout time.Duration) ([]Chunk, bool) {
+	if !res.IsBodyStream() {
+		return nil, false
+	}
+
+	type read struct {
+		data []byte
+		err  error
+	}
+
+	stream := res.BodyStream()
+	reads := make(chan read)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			buf := make([]byte, 4096)
+			n, err := stream.Read(buf)
+			select {
+			case reads <- read{buf[:n], err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var chunks []Chunk
+	var body bytes.Buffer
+	start := time.Now()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	timedOut := false
+DRAIN:
+	for {
+		select {
+		case r := <-reads:
+			if len(r.data) > 0 {
+				body.Write(r.data)
+				chunks = append(chunks, Chunk{Data: r.data, Elapsed: time.Since(start)})
+			}
+			if r.err != nil {
+				break DRAIN
+			}
+		case <-timer.C:
+			timedOut = true
+			break DRAIN
+		}
+	}
+
+	res.CloseBodyStream()
+	res.SetBodyRaw(body.Bytes())
+	return chunks, timedOut
+}
+
+func parseEvents(body []byte) []Event {
+	var events []Event
+	var event Event
+	var data []string
+	pending := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			if pending {
+				event.Data = strings.Join(data, "\n")
+				events = append(events, event)
+			}
+			event, data, pending = Event{}, nil, false
+			continue
+		}
+		if line[0] == ':' {
+			// comment
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			event.Id = value
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			if n, err := strconv.Atoi(value); err == nil {
+				event.Retry = n
+			}
+		default:
+			continue
+		}
+		pending = true
+	}
+
+	// the last event wasn't terminated by a blank line, which means
+	// the stream was cut (probably by our StreamTimeout)
+	if pending {
+		event.Data = strings.Join(data, "\n")
+		events = append(events, event)
+	}
+	return events
+}
+
+func isEventStream(contentType []byte) bool {
+	return bytes.HasPrefix(contentType, []byte("text/event-stream"))
+}
+
+// The response was streamed (it might or might not have been
+// cut by the StreamTimeout)
+func (r response) ExpectStreamed() response {
+	r.t.Helper()
+	if !r.Streamed {
+		assert.Fail(r.t, "expected a streamed response body")
+	}
+	return r
+}
+
+// The stream ended on its own, rather than by our StreamTimeout
+func (r response) ExpectStreamEnded() response {
+	r.t.Helper()
+	r.ExpectStreamed()
+	if r.StreamTimedOut {
+		assert.Fail(r.t, "expected the stream to end, but it was still open after the timeout")
+	}
+	return r
+}
+
+// The response contains exactly these events, in this order
+func (r response) ExpectEvents(expected ...Event) response {
+	r.t.Helper()
+	valid := len(r.Events) == len(expected)
+	if valid {
+		for i, e := range expected {
+			if r.Events[i] != e {
+				valid = false
+				break
+			}
+		}
+	}
+	if !valid {
+		assert.Fail(r.t, "\nexpected events:\n%s\ngot:\n%s", formatEvents(expected), formatEvents(r.Events))
+	}
+	return r
+}
+
+func formatEvents(events []Event) string {
+	if len(events) == 0 {
+		return "  (none)\n"
+	}
+	var sb strings.Builder
+	for i, e := range events {
+		fmt.Fprintf(&sb, "  %d: id=%q event=%q data=%q retry=%d\n", i, e.Id, e.Event, e.Data, e.Retry)
+	}
+	return sb.String()
+}
@@ -0,0 +1,436 @@
+package request
+
+// A websocket client for testing handlers which upgrade the
+// connection (e.g. with fasthttp/websocket's FastHTTPUpgrader).
+// The handler is served by a real fasthttp.Server over an
+// in-memory listener:
+//
+//   ws := request.WebSocket(t, handler)
+//   defer ws.Close()
+//   ws.Send("hello").Expect("hello back")
+//   ws.SendJSON(map[string]any{"id": 1}).ExpectJSON(map[string]any{"ok": true})
+//
+// Req(t).Path("/ws").User("u1").WebSocket(handler) works too, and
+// sends the builder's path, query and headers with the upgrade.
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/json"
+	"src.goblgobl.com/utils/typed"
+)
+
+// How long a websocket read or write can take
+var WebSocketTimeout = 2 * time.Second
+
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+type WSMessage struct {
+	Binary bool
+	Data   []byte
+}
+
+type WSClient struct {
+	t       *testing.T
+	conn    net.Conn
+	reader  *bufio.Reader
+	ln      *fasthttputil.InmemoryListener
+	timeout time.Duration
+	closed  bool
+	// set when a read timed out partway through a frame (or a
+	// fragmented message), after which the reader is out of sync
+	broken error
+	// set when the server closes the connection
+	closeCode   int
+	closeReason string
+}
+
+func WebSocket(t *testing.T, handler Handler) *WSClient {
+	t.Helper()
+	return Req(t).WebSocket(handler)
+}
+
+func (r RequestBuilder) WebSocket(handler Handler) *WSClient {
+	t := r.t
+	t.Helper()
+
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{Handler: fasthttp.RequestHandler(handler)}
+	go server.Serve(ln)
+
+	conn, err := ln.Dial()
+	if err != nil {
+		ln.Close()
+		assert.Fail(t, "websocket dial: %v", err)
+	}
+
+	ws := &WSClient{
+		t:       t,
+		ln:      ln,
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: WebSocketTimeout,
+	}
+	t.Cleanup(func() { ws.shutdown() })
+
+	ws.handshake(r)
+	return ws
+}
+
+func (ws *WSClient) handshake(r RequestBuilder) {
+	t := ws.t
+	t.Helper()
+
+	keyBytes := make([]byte, 16)
+	rand.Read(keyBytes)
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	uri := r.path
+	if len(r.query) > 0 {
+		uri += "?" + r.query.Encode()
+	}
+	host := r.host
+	if host == "" {
+		host = "test.goblgobl.local"
+	}
+
+	var req strings.Builder
+	fmt.Fprintf(&req, "GET %s HTTP/1.1\r\n", uri)
+	fmt.Fprintf(&req, "Host: %s\r\n", host)
+	req.WriteString("Upgrade: websocket\r\n")
+	req.WriteString("Connection: Upgrade\r\n")
+	fmt.Fprintf(&req, "Sec-WebSocket-Key: %s\r\n", key)
+	req.WriteString("Sec-WebSocket-Version: 13\r\n")
+	for k, v := range r.headers {
+		fmt.Fprintf(&req, "%s: %s\r\n", k, v)
+	}
+	req.WriteString("\r\n")
+
+	ws.conn.SetDeadline(time.Now().Add(ws.timeout))
+	if _, err := io.WriteString(ws.conn, req.String()); err != nil {
+		assert.Fail(t, "websocket handshake: %v", err)
+	}
+
+	res, err := http.ReadResponse(ws.reader, nil)
+	if err != nil {
+		assert.Fail(t, "websocket handshake: %v", err)
+	}
+	if res.StatusCode != 101 {
+		body, _ := io.ReadAll(res.Body)
+		assert.Fail(t, "\nexpected websocket upgrade (101)\n\t\t got: %d\nbody: %s", res.StatusCode, body)
+	}
+
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	if accept := res.Header.Get("Sec-WebSocket-Accept"); accept != base64.StdEncoding.EncodeToString(sum[:]) {
+		assert.Fail(t, "invalid Sec-WebSocket-Accept: %s", accept)
+	}
+}
+
+// How long a read or write can take (defaults to WebSocketTimeout)
+func (ws *WSClient) Timeout(timeout time.Duration) *WSClient {
+	ws.timeout = timeout
+	return ws
+}
+
+func (ws *WSClient) Send(message string) *WSClient {
+	ws.t.Helper()
+	ws.write(wsText, []byte(message))
+	return ws
+}
+
+func (ws *WSClient) SendBinary(message []byte) *WSClient {
+	ws.t.Helper()
+	ws.write(wsBinary, message)
+	return ws
+}
+
+func (ws *WSClient) SendJSON(message any) *WSClient {
+	ws.t.Helper()
+	data, err := json.Marshal(message)
+	if err != nil {
+		panic(err)
+	}
+	ws.write(wsText, data)
+	return ws
+}
+
+// The next message (replying to any ping along the way)
+func (ws *WSClient) Read() WSMessage {
+	ws.t.Helper()
+	message, err := ws.read()
+	if err != nil {
+		assert.Fail(ws.t, "websocket read: %v", err)
+	}
+	return message
+}
+
+// The next message, as json
+func (ws *WSClient) ReadJSON() typed.Typed {
+	ws.t.Helper()
+	message := ws.Read()
+	data, err := typed.Json(message.Data)
+	if err != nil {
+		assert.Fail(ws.t, "websocket message is not json: %s", message.Data)
+	}
+	return data
+}
+
+// The next message is exactly the given string
+func (ws *WSClient) Expect(expected string) *WSClient {
+	ws.t.Helper()
+	if actual := string(ws.Read().Data); actual != expected {
+		assert.Fail(ws.t, "\nexpected websocket message: %s\n\t\t\t got: %s", expected, actual)
+	}
+	return ws
+}
+
+// The next message is json equal to expected (which is serialized
+// to json for the comparison)
+func (ws *WSClient) ExpectJSON(expected any) *WSClient {
+	ws.t.Helper()
+	actual := ws.Read().Data
+
+	var a, e any
+	if err := json.Unmarshal(actual, &a); err != nil {
+		assert.Fail(ws.t, "websocket message is not json: %s", actual)
+	}
+	data, err := json.Marshal(expected)
+	if err != nil {
+		panic(err)
+	}
+	json.Unmarshal(data, &e)
+
+	if !reflect.DeepEqual(a, e) {
+		assert.Fail(ws.t, "\nexpected websocket message: %s\n\t\t\t got: %s", data, actual)
+	}
+	return ws
+}
+
+// Nothing is received for the given duration
+func (ws *WSClient) ExpectNothing(d time.Duration) *WSClient {
+	ws.t.Helper()
+	timeout := ws.timeout
+	ws.timeout = d
+	defer func() { ws.timeout = timeout }()
+
+	message, err := ws.read()
+	if err == nil {
+		assert.Fail(ws.t, "expected no websocket message, got: %s", message.Data)
+	}
+	if ws.broken != nil {
+		assert.Fail(ws.t, "expected no websocket message, got part of one: %v", ws.broken)
+	}
+	var timeoutErr interface{ Timeout() bool }
+	if !errors.As(err, &timeoutErr) || !timeoutErr.Timeout() {
+		assert.Fail(ws.t, "expected no websocket message, got: %v", err)
+	}
+	return ws
+}
+
+// The server closes the connection, optionally with the given code
+func (ws *WSClient) ExpectClosed(code ...int) *WSClient {
+	ws.t.Helper()
+	message, err := ws.read()
+	if err == nil {
+		assert.Fail(ws.t, "expected websocket to be closed, got: %s", message.Data)
+	}
+	if !ws.closed {
+		assert.Fail(ws.t, "expected websocket to be closed, got: %v", err)
+	}
+	if len(code) == 1 && ws.closeCode != code[0] {
+		assert.Fail(ws.t, "\nexpected close code: %d\n\t\t got: %d (%s)", code[0], ws.closeCode, ws.closeReason)
+	}
+	return ws
+}
+
+// Sends a close frame, waits (up to the timeout) for the server
+// to acknowledge it and shuts everything down
+func (ws *WSClient) Close() {
+	if !ws.closed {
+		ws.closed = true
+		payload := make([]byte, 2)
+		binary.BigEndian.PutUint16(payload, 1000)
+		if ws.writeFrame(wsClose, payload) == nil {
+			ws.conn.SetReadDeadline(time.Now().Add(ws.timeout))
+			for {
+				_, opcode, _, _, err := ws.readFrame()
+				if err != nil || opcode == wsClose {
+					break
+				}
+			}
+		}
+	}
+	ws.shutdown()
+}
+
+func (ws *WSClient) shutdown() {
+	ws.conn.Close()
+	ws.ln.Close()
+}
+
+func (ws *WSClient) write(opcode byte, data []byte) {
+	ws.t.Helper()
+	if err := ws.writeFrame(opcode, data); err != nil {
+		assert.Fail(ws.t, "websocket write: %v", err)
+	}
+}
+
+// Client frames are always masked
+func (ws *WSClient) writeFrame(opcode byte, data []byte) error {
+	l := len(data)
+	frame := make([]byte, 0, l+14)
+	frame = append(frame, 0x80|opcode)
+	switch {
+	case l < 126:
+		frame = append(frame, 0x80|byte(l))
+	case l <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(l))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(l))
+	}
+
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	frame = append(frame, mask...)
+	for i, b := range data {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	ws.conn.SetWriteDeadline(time.Now().Add(ws.timeout))
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+// Reads a full (possibly fragmented) data message
+func (ws *WSClient) read() (WSMessage, error) {
+	if ws.closed {
+		return WSMessage{}, errors.New("websocket is closed")
+	}
+	if ws.broken != nil {
+		return WSMessage{}, ws.broken
+	}
+
+	var message WSMessage
+	var data []byte
+	started := false
+	ws.conn.SetReadDeadline(time.Now().Add(ws.timeout))
+	for {
+		fin, opcode, payload, partial, err := ws.readFrame()
+		if err != nil {
+			if partial || started {
+				ws.broken = fmt.Errorf("websocket client is unusable, a read failed partway through a message: %w", err)
+			}
+			return message, err
+		}
+		switch opcode {
+		case wsPing:
+			ws.writeFrame(wsPong, payload)
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			ws.closed = true
+			if len(payload) >= 2 {
+				ws.closeCode = int(binary.BigEndian.Uint16(payload))
+				ws.closeReason = string(payload[2:])
+			}
+			// echo the close, as the protocol requires
+			ws.writeFrame(wsClose, payload)
+			return message, errors.New("websocket closed by server")
+		case wsText, wsBinary:
+			if started {
+				return message, errors.New("websocket protocol error: new message before previous one finished")
+			}
+			started = true
+			message.Binary = opcode == wsBinary
+		case wsContinuation:
+			if !started {
+				return message, errors.New("websocket protocol error: unexpected continuation frame")
+			}
+		}
+		data = append(data, payload...)
+		if fin {
+			message.Data = data
+			return message, nil
+		}
+	}
+}
+
+// partial is true when the error happened after some of the
+// frame was read
+func (ws *WSClient) readFrame() (fin bool, opcode byte, payload []byte, partial bool, err error) {
+	read := func(n uint64) ([]byte, error) {
+		buf := make([]byte, n)
+		got, err := io.ReadFull(ws.reader, buf)
+		if got > 0 {
+			partial = true
+		}
+		return buf, err
+	}
+
+	header, err := read(2)
+	if err != nil {
+		return false, 0, nil, partial, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+
+	l := uint64(header[1] & 0x7F)
+	switch l {
+	case 126:
+		ext, err := read(2)
+		if err != nil {
+			return false, 0, nil, true, err
+		}
+		l = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext, err := read(8)
+		if err != nil {
+			return false, 0, nil, true, err
+		}
+		l = binary.BigEndian.Uint64(ext)
+	}
+
+	var mask []byte
+	if masked {
+		if mask, err = read(4); err != nil {
+			return false, 0, nil, true, err
+		}
+	}
+
+	if payload, err = read(l); err != nil {
+		return false, 0, nil, true, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, true, nil
+}
@@ -0,0 +1,200 @@
+package request
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"src.goblgobl.com/tests/assert"
+)
+
+func Test_WebSocket_Echo(t *testing.T) {
+	ws := WebSocket(t, upgrade(func(s *wsServer) {
+		for {
+			opcode, payload, ok := s.read()
+			if !ok || opcode == wsClose {
+				s.write(wsClose, payload)
+				return
+			}
+			s.write(opcode, payload)
+		}
+	}))
+	ws.Send("hello").Expect("hello")
+	ws.SendJSON(map[string]any{"id": 1}).ExpectJSON(map[string]any{"id": 1})
+	ws.SendBinary([]byte{1, 2, 3})
+	message := ws.Read()
+	assert.True(t, message.Binary)
+	assert.Bytes(t, message.Data, []byte{1, 2, 3})
+
+	// extended (16-bit) payload length
+	long := strings.Repeat("a", 300)
+	ws.Send(long).Expect(long)
+	ws.Close()
+}
+
+func Test_WebSocket_PathAndHeaders(t *testing.T) {
+	var path, user string
+	ws := Req(t).Path("/ws").Header("User", "u1").WebSocket(upgrade(func(s *wsServer) {
+		s.write(wsText, []byte("ready"))
+		s.read()
+	}, func(conn *fasthttp.RequestCtx) {
+		path = string(conn.Path())
+		user = string(conn.Request.Header.Peek("User"))
+	}))
+	ws.Expect("ready")
+	assert.Equal(t, path, "/ws")
+	assert.Equal(t, user, "u1")
+}
+
+func Test_WebSocket_Fragmented_And_Ping(t *testing.T) {
+	type frame struct {
+		opcode  byte
+		payload []byte
+	}
+	// asserted on the test's goroutine, not the server's
+	received := make(chan frame, 1)
+	ws := WebSocket(t, upgrade(func(s *wsServer) {
+		s.writeFrame(false, wsText, []byte("hel"))
+		s.write(wsPing, []byte("p"))
+		s.writeFrame(true, wsContinuation, []byte("lo"))
+		opcode, payload, _ := s.read()
+		received <- frame{opcode, payload}
+		s.read()
+	}))
+	ws.Expect("hello")
+
+	select {
+	case f := <-received:
+		assert.Equal(t, f.opcode, byte(wsPong))
+		assert.Equal(t, string(f.payload), "p")
+	case <-time.After(time.Second):
+		t.Fatal("server didn't receive a pong")
+	}
+}
+
+func Test_WebSocket_ServerClose(t *testing.T) {
+	ws := WebSocket(t, upgrade(func(s *wsServer) {
+		payload := binary.BigEndian.AppendUint16(nil, 4000)
+		s.write(wsClose, append(payload, "bye"...))
+		s.read()
+	}))
+	ws.ExpectClosed(4000)
+	assert.Equal(t, ws.closeReason, "bye")
+}
+
+func Test_WebSocket_ExpectNothing(t *testing.T) {
+	ws := WebSocket(t, upgrade(func(s *wsServer) {
+		time.Sleep(100 * time.Millisecond)
+		s.write(wsText, []byte("late"))
+		s.read()
+	}))
+	ws.ExpectNothing(20 * time.Millisecond).Expect("late")
+}
+
+func Test_WebSocket_PartialFrame_Breaks_Client(t *testing.T) {
+	release := make(chan struct{})
+	ws := WebSocket(t, upgrade(func(s *wsServer) {
+		// the header of a 5 byte text frame, but only 2 bytes of it
+		s.conn.Write([]byte{0x80 | wsText, 5, 'h', 'e'})
+		<-release
+		s.conn.Write([]byte("llo"))
+		s.read()
+	}))
+	defer close(release)
+
+	_, err := ws.Timeout(20 * time.Millisecond).read()
+	assert.NotNil(t, err)
+	assert.NotNil(t, ws.broken)
+
+	_, err = ws.read()
+	assert.ErrorContains(t, err, "unusable")
+}
+
+func Test_WebSocket_Timeout_Between_Frames_Is_Clean(t *testing.T) {
+	release := make(chan struct{})
+	ws := WebSocket(t, upgrade(func(s *wsServer) {
+		<-release
+		s.write(wsText, []byte("hi"))
+		s.read()
+	}))
+	_, err := ws.Timeout(20 * time.Millisecond).read()
+	assert.NotNil(t, err)
+	assert.Nil(t, ws.broken)
+
+	close(release)
+	ws.Timeout(WebSocketTimeout).Expect("hi")
+}
+
+// A minimal server side: completes the upgrade then hands the
+// hijacked connection to fn
+func upgrade(fn func(s *wsServer), inspect ...func(*fasthttp.RequestCtx)) Handler {
+	return func(conn *fasthttp.RequestCtx) {
+		for _, i := range inspect {
+			i(conn)
+		}
+		key := string(conn.Request.Header.Peek("Sec-WebSocket-Key"))
+		sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+		conn.SetStatusCode(fasthttp.StatusSwitchingProtocols)
+		conn.Response.Header.Set("Upgrade", "websocket")
+		conn.Response.Header.Set("Connection", "Upgrade")
+		conn.Response.Header.Set("Sec-WebSocket-Accept", base64.StdEncoding.EncodeToString(sum[:]))
+		conn.Hijack(func(c net.Conn) {
+			fn(&wsServer{conn: c, reader: bufio.NewReader(c)})
+		})
+	}
+}
+
+type wsServer struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (s *wsServer) write(opcode byte, payload []byte) {
+	s.writeFrame(true, opcode, payload)
+}
+
+// server frames are never masked
+func (s *wsServer) writeFrame(fin bool, opcode byte, payload []byte) {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	if l := len(payload); l < 126 {
+		frame = append(frame, byte(l))
+	} else {
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(l))
+	}
+	s.conn.Write(append(frame, payload...))
+}
+
+func (s *wsServer) read() (byte, []byte, bool) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(s.reader, header); err != nil {
+		return 0, nil, false
+	}
+	l := int(header[1] & 0x7F)
+	if l == 126 {
+		ext := make([]byte, 2)
+		io.ReadFull(s.reader, ext)
+		l = int(binary.BigEndian.Uint16(ext))
+	}
+	mask := make([]byte, 4)
+	io.ReadFull(s.reader, mask)
+	payload := make([]byte, l)
+	if _, err := io.ReadFull(s.reader, payload); err != nil {
+		return 0, nil, false
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return header[0] & 0x0F, payload, true
+}
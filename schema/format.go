@@ -0,0 +1,83 @@
+package schema
+
+import (
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	formatLock sync.RWMutex
+	formats    = map[string]func(string) bool{
+		"uuid":      isUUID,
+		"date-time": isDateTime,
+		"date":      isDate,
+		"time":      isTime,
+		"email":     isEmail,
+		"uri":       isURI,
+		"ipv4":      isIPv4,
+		"ipv6":      isIPv6,
+	}
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// Adds (or replaces) a string format. Unknown formats are
+// ignored, as the spec allows.
+func RegisterFormat(name string, valid func(string) bool) {
+	formatLock.Lock()
+	defer formatLock.Unlock()
+	formats[name] = valid
+}
+
+func lookupFormat(name string) func(string) bool {
+	formatLock.RLock()
+	defer formatLock.RUnlock()
+	return formats[name]
+}
+
+func isUUID(value string) bool {
+	return uuidPattern.MatchString(value)
+}
+
+func isDateTime(value string) bool {
+	_, err := time.Parse(time.RFC3339Nano, value)
+	return err == nil
+}
+
+func isDate(value string) bool {
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
+
+func isTime(value string) bool {
+	_, err := time.Parse("15:04:05Z07:00", value)
+	if err != nil {
+		_, err = time.Parse("15:04:05.999999999Z07:00", value)
+	}
+	return err == nil
+}
+
+// A bare address (no display name, no <>)
+func isEmail(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value && address.Name == "" && strings.Contains(value, "@")
+}
+
+func isURI(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme != ""
+}
+
+func isIPv4(value string) bool {
+	ip := net.ParseIP(value)
+	return ip != nil && ip.To4() != nil && !strings.Contains(value, ":")
+}
+
+func isIPv6(value string) bool {
+	ip := net.ParseIP(value)
+	return ip != nil && strings.Contains(value, ":")
+}
@@ -0,0 +1,612 @@
+package schema
+
+/*
+A small JSON Schema validator (the commonly used subset of
+draft 2020-12, plus OpenAPI 3.0's nullable and boolean
+exclusiveMinimum/exclusiveMaximum). The uuid, date-time, date,
+time, email, uri, ipv4 and ipv6 formats are checked, others can
+be added with RegisterFormat.
+
+Every violation is reported with a JSON pointer to the offending
+value and the path of the schema keyword which rejected it.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type Violation struct {
+	// JSON pointer to the value, e.g. /items/2/name ("" for the root)
+	Path string
+	// path of the schema keyword, e.g. #/properties/items/items/properties/name/type
+	SchemaPath string
+	Message    string
+}
+
+func (v Violation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s (%s)", path, v.Message, v.SchemaPath)
+}
+
+type Schema struct {
+	root    any
+	node    any
+	pointer string
+}
+
+// The schema can be a map[string]any (or anything that serializes
+// to a json object), raw json as []byte, or a json string.
+func New(schema any) (*Schema, error) {
+	var root any
+	switch s := schema.(type) {
+	case []byte:
+		if err := decode(s, &root); err != nil {
+			return nil, fmt.Errorf("schema is not valid json: %w", err)
+		}
+	case string:
+		if err := decode([]byte(s), &root); err != nil {
+			return nil, fmt.Errorf("schema is not valid json: %w", err)
+		}
+	case *Schema:
+		return s, nil
+	default:
+		var err error
+		if root, err = Normalize(schema); err != nil {
+			return nil, err
+		}
+	}
+
+	switch root.(type) {
+	case map[string]any, bool:
+		return &Schema{root: root, node: root, pointer: "#"}, nil
+	default:
+		return nil, fmt.Errorf("schema must be a json object, got %T", root)
+	}
+}
+
+// Loads a schema from a json file
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(data)
+}
+
+// Like New, but a string which isn't json is treated as the
+// path of a json file
+func Open(schema any) (*Schema, error) {
+	if path, ok := schema.(string); ok && !isJSON(path) {
+		return Load(path)
+	}
+	return New(schema)
+}
+
+func isJSON(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "{") || s == "true" || s == "false"
+}
+
+func Must(schema any) *Schema {
+	s, err := New(schema)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// A schema within this one, e.g. "#/components/schemas/User".
+// $refs within it are resolved against the whole document.
+func (s *Schema) Ref(ref string) (*Schema, error) {
+	node, err := s.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root: s.root, node: node, pointer: ref}, nil
+}
+
+// The raw (normalized) schema
+func (s *Schema) Node() any {
+	return s.node
+}
+
+// Validates the value, which can be anything that serializes to
+// json (a typed.Typed, a struct, a map, ...) or json.RawMessage.
+func (s *Schema) Validate(value any) []Violation {
+	normalized, err := Normalize(value)
+	if err != nil {
+		return []Violation{{SchemaPath: s.pointer, Message: err.Error()}}
+	}
+	v := &validator{schema: s}
+	v.validate(normalized, s.node, "", s.pointer)
+	return v.violations
+}
+
+// Converts the value into the generic json representation
+// (map[string]any, []any, json.Number, string, bool, nil)
+func Normalize(value any) (any, error) {
+	var data []byte
+	switch v := value.(type) {
+	case json.RawMessage:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+	var normalized any
+	if err := decode(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+func decode(data []byte, into any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(into)
+}
+
+func (s *Schema) resolve(ref string) (any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("only local $refs are supported: %s", ref)
+	}
+	node := s.root
+	pointer := strings.TrimPrefix(ref[1:], "/")
+	if pointer == "" {
+		return node, nil
+	}
+	for _, part := range strings.Split(pointer, "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		switch n := node.(type) {
+		case map[string]any:
+			var exists bool
+			if node, exists = n[part]; !exists {
+				return nil, fmt.Errorf("unresolvable $ref: %s", ref)
+			}
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("unresolvable $ref: %s", ref)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("unresolvable $ref: %s", ref)
+		}
+	}
+	return node, nil
+}
+
+type validator struct {
+	schema     *Schema
+	violations []Violation
+	depth      int
+}
+
+func (v *validator) fail(path string, schemaPath string, format string, args ...any) {
+	v.violations = append(v.violations, Violation{
+		Path:       path,
+		SchemaPath: schemaPath,
+		Message:    fmt.Sprintf(format, args...),
+	})
+}
+
+// validates in a throwaway validator, for anyOf/oneOf/not
+func (v *validator) valid(value any, node any, path string, schemaPath string) bool {
+	sub := &validator{schema: v.schema, depth: v.depth}
+	sub.validate(value, node, path, schemaPath)
+	return len(sub.violations) == 0
+}
+
+func (v *validator) validate(value any, node any, path string, schemaPath string) {
+	v.depth++
+	defer func() { v.depth-- }()
+	if v.depth > 100 {
+		v.fail(path, schemaPath, "schema recursion too deep")
+		return
+	}
+
+	schema, ok := node.(map[string]any)
+	if !ok {
+		if b, ok := node.(bool); ok && !b {
+			v.fail(path, schemaPath, "no value is allowed here")
+		}
+		return
+	}
+
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return
+		}
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := v.schema.resolve(ref)
+		if err != nil {
+			v.fail(path, schemaPath+"/$ref", "%s", err)
+		} else {
+			v.validate(value, target, path, ref)
+		}
+		// OpenAPI 3.0 ignores siblings of $ref, 2020-12 doesn't. Since
+		// the siblings are usually just descriptions, we apply them.
+	}
+
+	if t, exists := schema["type"]; exists && !v.checkType(value, t, path, schemaPath+"/type") {
+		// everything else is going to fail/be meaningless
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if equal(value, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, schemaPath+"/enum", "must be one of %s, got %s", jsonString(enum), jsonString(value))
+		}
+	}
+
+	if c, exists := schema["const"]; exists && !equal(value, c) {
+		v.fail(path, schemaPath+"/const", "must be %s, got %s", jsonString(c), jsonString(value))
+	}
+
+	switch typed := value.(type) {
+	case string:
+		v.validateString(typed, schema, path, schemaPath)
+	case json.Number:
+		v.validateNumber(typed, schema, path, schemaPath)
+	case []any:
+		v.validateArray(typed, schema, path, schemaPath)
+	case map[string]any:
+		v.validateObject(typed, schema, path, schemaPath)
+	}
+
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for i, sub := range allOf {
+			v.validate(value, sub, path, schemaPath+"/allOf/"+strconv.Itoa(i))
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		matched := false
+		for i, sub := range anyOf {
+			if v.valid(value, sub, path, schemaPath+"/anyOf/"+strconv.Itoa(i)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, schemaPath+"/anyOf", "must match at least one schema in anyOf")
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		matches := 0
+		for i, sub := range oneOf {
+			if v.valid(value, sub, path, schemaPath+"/oneOf/"+strconv.Itoa(i)) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			v.fail(path, schemaPath+"/oneOf", "must match exactly one schema in oneOf, matched %d", matches)
+		}
+	}
+
+	if not, exists := schema["not"]; exists {
+		if v.valid(value, not, path, schemaPath+"/not") {
+			v.fail(path, schemaPath+"/not", "must not match the schema in not")
+		}
+	}
+}
+
+func (v *validator) checkType(value any, t any, path string, schemaPath string) bool {
+	var types []string
+	switch t := t.(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, s := range t {
+			if s, ok := s.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+
+	actual := typeOf(value)
+	for _, expected := range types {
+		if expected == actual || (expected == "number" && actual == "integer") {
+			return true
+		}
+	}
+	v.fail(path, schemaPath, "expected %s, got %s", strings.Join(types, " or "), actual)
+	return false
+}
+
+func typeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if r, ok := new(big.Rat).SetString(string(v)); ok && r.IsInt() {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func (v *validator) validateString(value string, schema map[string]any, path string, schemaPath string) {
+	l := utf8.RuneCountInString(value)
+	if min, ok := integer(schema["minLength"]); ok && l < min {
+		v.fail(path, schemaPath+"/minLength", "length must be >= %d, got %d", min, l)
+	}
+	if max, ok := integer(schema["maxLength"]); ok && l > max {
+		v.fail(path, schemaPath+"/maxLength", "length must be <= %d, got %d", max, l)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			v.fail(path, schemaPath+"/pattern", "invalid pattern %q: %s", pattern, err)
+		} else if !re.MatchString(value) {
+			v.fail(path, schemaPath+"/pattern", "must match pattern %q, got %q", pattern, value)
+		}
+	}
+	if format, ok := schema["format"].(string); ok {
+		if valid := lookupFormat(format); valid != nil && !valid(value) {
+			v.fail(path, schemaPath+"/format", "must be a valid %s, got %q", format, value)
+		}
+	}
+}
+
+func (v *validator) validateNumber(value json.Number, schema map[string]any, path string, schemaPath string) {
+	n, _ := new(big.Rat).SetString(string(value))
+	if n == nil {
+		v.fail(path, schemaPath, "invalid number: %s", value)
+		return
+	}
+
+	if min, ok := rat(schema["minimum"]); ok {
+		// OpenAPI 3.0 style
+		if exclusive, _ := schema["exclusiveMinimum"].(bool); exclusive {
+			if n.Cmp(min) <= 0 {
+				v.fail(path, schemaPath+"/exclusiveMinimum", "must be > %s, got %s", min.RatString(), value)
+			}
+		} else if n.Cmp(min) < 0 {
+			v.fail(path, schemaPath+"/minimum", "must be >= %s, got %s", min.RatString(), value)
+		}
+	}
+	if max, ok := rat(schema["maximum"]); ok {
+		if exclusive, _ := schema["exclusiveMaximum"].(bool); exclusive {
+			if n.Cmp(max) >= 0 {
+				v.fail(path, schemaPath+"/exclusiveMaximum", "must be < %s, got %s", max.RatString(), value)
+			}
+		} else if n.Cmp(max) > 0 {
+			v.fail(path, schemaPath+"/maximum", "must be <= %s, got %s", max.RatString(), value)
+		}
+	}
+	if min, ok := rat(schema["exclusiveMinimum"]); ok && n.Cmp(min) <= 0 {
+		v.fail(path, schemaPath+"/exclusiveMinimum", "must be > %s, got %s", min.RatString(), value)
+	}
+	if max, ok := rat(schema["exclusiveMaximum"]); ok && n.Cmp(max) >= 0 {
+		v.fail(path, schemaPath+"/exclusiveMaximum", "must be < %s, got %s", max.RatString(), value)
+	}
+	if multiple, ok := rat(schema["multipleOf"]); ok && multiple.Sign() != 0 {
+		if !new(big.Rat).Quo(n, multiple).IsInt() {
+			v.fail(path, schemaPath+"/multipleOf", "must be a multiple of %s, got %s", multiple.RatString(), value)
+		}
+	}
+}
+
+func (v *validator) validateArray(value []any, schema map[string]any, path string, schemaPath string) {
+	l := len(value)
+	if min, ok := integer(schema["minItems"]); ok && l < min {
+		v.fail(path, schemaPath+"/minItems", "must have at least %d items, got %d", min, l)
+	}
+	if max, ok := integer(schema["maxItems"]); ok && l > max {
+		v.fail(path, schemaPath+"/maxItems", "must have at most %d items, got %d", max, l)
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+	UNIQUE:
+		for i := 0; i < l; i++ {
+			for j := i + 1; j < l; j++ {
+				if equal(value[i], value[j]) {
+					v.fail(path, schemaPath+"/uniqueItems", "items must be unique, %d and %d are equal", i, j)
+					break UNIQUE
+				}
+			}
+		}
+	}
+
+	start := 0
+	if prefix, ok := schema["prefixItems"].([]any); ok {
+		for i, sub := range prefix {
+			if i >= l {
+				break
+			}
+			v.validate(value[i], sub, path+"/"+strconv.Itoa(i), schemaPath+"/prefixItems/"+strconv.Itoa(i))
+		}
+		start = len(prefix)
+	}
+	if items, exists := schema["items"]; exists {
+		for i := start; i < l; i++ {
+			v.validate(value[i], items, path+"/"+strconv.Itoa(i), schemaPath+"/items")
+		}
+	}
+
+	if contains, exists := schema["contains"]; exists {
+		found := false
+		for i, item := range value {
+			if v.valid(item, contains, path+"/"+strconv.Itoa(i), schemaPath+"/contains") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, schemaPath+"/contains", "must contain at least one item matching the schema in contains")
+		}
+	}
+}
+
+func (v *validator) validateObject(value map[string]any, schema map[string]any, path string, schemaPath string) {
+	l := len(value)
+	if min, ok := integer(schema["minProperties"]); ok && l < min {
+		v.fail(path, schemaPath+"/minProperties", "must have at least %d properties, got %d", min, l)
+	}
+	if max, ok := integer(schema["maxProperties"]); ok && l > max {
+		v.fail(path, schemaPath+"/maxProperties", "must have at most %d properties, got %d", max, l)
+	}
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, exists := value[name]; !exists {
+				v.fail(path+"/"+escape(name), schemaPath+"/required", "is required")
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	patterns, _ := schema["patternProperties"].(map[string]any)
+	additional, hasAdditional := schema["additionalProperties"]
+
+	// sorted, so that violations are reported in a stable order
+	keys := make([]string, 0, l)
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		item := value[key]
+		itemPath := path + "/" + escape(key)
+		matched := false
+		if sub, exists := properties[key]; exists {
+			matched = true
+			v.validate(item, sub, itemPath, schemaPath+"/properties/"+escape(key))
+		}
+		for pattern, sub := range patterns {
+			if re, err := compilePattern(pattern); err == nil && re.MatchString(key) {
+				matched = true
+				v.validate(item, sub, itemPath, schemaPath+"/patternProperties/"+escape(pattern))
+			}
+		}
+		if !matched && hasAdditional {
+			if b, ok := additional.(bool); ok && !b {
+				v.fail(itemPath, schemaPath+"/additionalProperties", "is not allowed")
+			} else {
+				v.validate(item, additional, itemPath, schemaPath+"/additionalProperties")
+			}
+		}
+	}
+}
+
+var (
+	patternLock  sync.Mutex
+	patternCache = make(map[string]*regexp.Regexp)
+)
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patternLock.Lock()
+	defer patternLock.Unlock()
+	if re, exists := patternCache[pattern]; exists {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patternCache[pattern] = re
+	return re, nil
+}
+
+func integer(value any) (int, bool) {
+	if n, ok := value.(json.Number); ok {
+		i, err := n.Int64()
+		return int(i), err == nil
+	}
+	return 0, false
+}
+
+func rat(value any) (*big.Rat, bool) {
+	if n, ok := value.(json.Number); ok {
+		return new(big.Rat).SetString(string(n))
+	}
+	return nil, false
+}
+
+// json equality, where 1 == 1.0
+func equal(a any, b any) bool {
+	na, aok := a.(json.Number)
+	nb, bok := b.(json.Number)
+	if aok && bok {
+		ra, _ := new(big.Rat).SetString(string(na))
+		rb, _ := new(big.Rat).SetString(string(nb))
+		return ra != nil && rb != nil && ra.Cmp(rb) == 0
+	}
+
+	switch a := a.(type) {
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, exists := b[k]
+			if !exists || !equal(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+func jsonString(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,194 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// (assert imports this package, so these tests stick to testing)
+
+type violation struct {
+	path       string
+	schemaPath string
+}
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		value    string
+		expected []violation
+	}{
+		{"type valid", `{"type": "string"}`, `"leto"`, nil},
+		{"type invalid", `{"type": "string"}`, `9`, []violation{{"", "#/type"}}},
+		{"type list", `{"type": ["string", "null"]}`, `null`, nil},
+		{"type list invalid", `{"type": ["string", "null"]}`, `true`, []violation{{"", "#/type"}}},
+		{"integer is a number", `{"type": "number"}`, `9`, nil},
+		{"integer with fraction", `{"type": "integer"}`, `9.0`, nil},
+		{"integer invalid", `{"type": "integer"}`, `9.5`, []violation{{"", "#/type"}}},
+		{"true schema", `true`, `{"any": 1}`, nil},
+		{"false schema", `false`, `1`, []violation{{"", "#"}}},
+
+		{"nullable", `{"type": "string", "nullable": true}`, `null`, nil},
+		{"not nullable", `{"type": "string"}`, `null`, []violation{{"", "#/type"}}},
+
+		{"required", `{"type": "object", "required": ["id", "name"]}`, `{"id": 1}`, []violation{{"/name", "#/required"}}},
+		{"properties", `{"properties": {"id": {"type": "integer"}}}`, `{"id": "1", "other": 2}`, []violation{{"/id", "#/properties/id/type"}}},
+		{"additionalProperties false", `{"properties": {"id": {}}, "additionalProperties": false}`, `{"id": 1, "b": 2, "a": 3}`, []violation{{"/a", "#/additionalProperties"}, {"/b", "#/additionalProperties"}}},
+		{"additionalProperties schema", `{"additionalProperties": {"type": "integer"}}`, `{"a": 1, "b": "2"}`, []violation{{"/b", "#/additionalProperties/type"}}},
+		{"patternProperties", `{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": false}`, `{"x-a": "1", "x-b": 2}`, []violation{{"/x-b", "#/patternProperties/^x-/type"}}},
+		{"min/maxProperties", `{"minProperties": 2}`, `{"a": 1}`, []violation{{"", "#/minProperties"}}},
+		{"escaped paths", `{"properties": {"a/b": {"type": "string"}}}`, `{"a/b": 1}`, []violation{{"/a~1b", "#/properties/a~1b/type"}}},
+
+		{"enum", `{"enum": ["a", 1]}`, `1.0`, nil},
+		{"enum invalid", `{"enum": ["a", 1]}`, `"b"`, []violation{{"", "#/enum"}}},
+		{"const", `{"const": {"a": [1, 2]}}`, `{"a": [1, 2]}`, nil},
+		{"const invalid", `{"const": {"a": [1, 2]}}`, `{"a": [2, 1]}`, []violation{{"", "#/const"}}},
+
+		{"minLength", `{"minLength": 3}`, `"ab"`, []violation{{"", "#/minLength"}}},
+		{"minLength counts runes", `{"minLength": 2, "maxLength": 2}`, `"éé"`, nil},
+		{"maxLength", `{"maxLength": 1}`, `"ab"`, []violation{{"", "#/maxLength"}}},
+		{"pattern", `{"pattern": "^[a-z]+$"}`, `"abc"`, nil},
+		{"pattern invalid", `{"pattern": "^[a-z]+$"}`, `"ab1"`, []violation{{"", "#/pattern"}}},
+		{"bad pattern", `{"pattern": "("}`, `"a"`, []violation{{"", "#/pattern"}}},
+
+		{"format uuid", `{"format": "uuid"}`, `"d3f1b5a2-6c4e-4b8a-9f0e-1a2b3c4d5e6f"`, nil},
+		{"format uuid invalid", `{"format": "uuid"}`, `"d3f1b5a2"`, []violation{{"", "#/format"}}},
+		{"format date-time", `{"format": "date-time"}`, `"2023-04-05T06:07:08.123Z"`, nil},
+		{"format date-time invalid", `{"format": "date-time"}`, `"2023-04-05"`, []violation{{"", "#/format"}}},
+		{"format date", `{"format": "date"}`, `"2023-04-05"`, nil},
+		{"format time", `{"format": "time"}`, `"06:07:08+02:00"`, nil},
+		{"format email", `{"format": "email"}`, `"leto@example.com"`, nil},
+		{"format email invalid", `{"format": "email"}`, `"Leto <leto@example.com>"`, []violation{{"", "#/format"}}},
+		{"format uri", `{"format": "uri"}`, `"https://example.com/a"`, nil},
+		{"format uri invalid", `{"format": "uri"}`, `"/a"`, []violation{{"", "#/format"}}},
+		{"format ipv4", `{"format": "ipv4"}`, `"127.0.0.1"`, nil},
+		{"format ipv4 invalid", `{"format": "ipv4"}`, `"::1"`, []violation{{"", "#/format"}}},
+		{"format ipv6", `{"format": "ipv6"}`, `"::1"`, nil},
+		{"format unknown", `{"format": "nope"}`, `"anything"`, nil},
+		{"format ignores other types", `{"format": "uuid"}`, `9`, nil},
+
+		{"minimum", `{"minimum": 1}`, `1`, nil},
+		{"minimum invalid", `{"minimum": 1}`, `0.5`, []violation{{"", "#/minimum"}}},
+		{"maximum invalid", `{"maximum": 1}`, `1.5`, []violation{{"", "#/maximum"}}},
+		{"3.0 exclusiveMinimum", `{"minimum": 1, "exclusiveMinimum": true}`, `1`, []violation{{"", "#/exclusiveMinimum"}}},
+		{"3.0 exclusiveMinimum false", `{"minimum": 1, "exclusiveMinimum": false}`, `1`, nil},
+		{"3.0 exclusiveMaximum", `{"maximum": 1, "exclusiveMaximum": true}`, `1`, []violation{{"", "#/exclusiveMaximum"}}},
+		{"2020-12 exclusiveMinimum", `{"exclusiveMinimum": 1}`, `1`, []violation{{"", "#/exclusiveMinimum"}}},
+		{"2020-12 exclusiveMinimum valid", `{"exclusiveMinimum": 1}`, `1.01`, nil},
+		{"2020-12 exclusiveMaximum", `{"exclusiveMaximum": 1}`, `1`, []violation{{"", "#/exclusiveMaximum"}}},
+		{"multipleOf", `{"multipleOf": 0.1}`, `0.3`, nil},
+		{"multipleOf invalid", `{"multipleOf": 2}`, `3`, []violation{{"", "#/multipleOf"}}},
+		{"large integers", `{"maximum": 9007199254740993}`, `9007199254740994`, []violation{{"", "#/maximum"}}},
+
+		{"items", `{"type": "array", "items": {"type": "integer"}}`, `[1, "2", 3, "4"]`, []violation{{"/1", "#/items/type"}, {"/3", "#/items/type"}}},
+		{"prefixItems", `{"prefixItems": [{"type": "string"}], "items": {"type": "integer"}}`, `["a", 1, "b"]`, []violation{{"/2", "#/items/type"}}},
+		{"minItems", `{"minItems": 1}`, `[]`, []violation{{"", "#/minItems"}}},
+		{"maxItems", `{"maxItems": 1}`, `[1, 2]`, []violation{{"", "#/maxItems"}}},
+		{"uniqueItems", `{"uniqueItems": true}`, `[1, 2, 1.0]`, []violation{{"", "#/uniqueItems"}}},
+		{"contains", `{"contains": {"const": 2}}`, `[1, 2]`, nil},
+		{"contains invalid", `{"contains": {"const": 2}}`, `[1, 3]`, []violation{{"", "#/contains"}}},
+
+		{"allOf", `{"allOf": [{"minimum": 1}, {"maximum": 2}]}`, `3`, []violation{{"", "#/allOf/1/maximum"}}},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `1`, nil},
+		{"anyOf invalid", `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `true`, []violation{{"", "#/anyOf"}}},
+		{"oneOf", `{"oneOf": [{"type": "string"}, {"type": "integer"}]}`, `"a"`, nil},
+		{"oneOf none", `{"oneOf": [{"type": "string"}, {"type": "integer"}]}`, `true`, []violation{{"", "#/oneOf"}}},
+		{"oneOf many", `{"oneOf": [{"type": "number"}, {"type": "integer"}]}`, `1`, []violation{{"", "#/oneOf"}}},
+		{"not", `{"not": {"type": "string"}}`, `1`, nil},
+		{"not invalid", `{"not": {"type": "string"}}`, `"a"`, []violation{{"", "#/not"}}},
+
+		{"$ref", `{"$defs": {"id": {"type": "integer"}}, "properties": {"id": {"$ref": "#/$defs/id"}}}`, `{"id": "1"}`, []violation{{"/id", "#/$defs/id/type"}}},
+		{"$ref nested", `{"$defs": {"user": {"properties": {"id": {"type": "integer"}}}}, "items": {"$ref": "#/$defs/user"}}`, `[{"id": 1}, {"id": "2"}]`, []violation{{"/1/id", "#/$defs/user/properties/id/type"}}},
+		{"$ref unresolvable", `{"$ref": "#/$defs/nope"}`, `1`, []violation{{"", "#/$ref"}}},
+		{"$ref remote", `{"$ref": "other.json#/a"}`, `1`, []violation{{"", "#/$ref"}}},
+		{"$ref recursive", `{"properties": {"child": {"$ref": "#"}}, "required": ["id"]}`, `{"id": 1, "child": {"id": 2, "child": {}}}`, []violation{{"/child/child/id", "#/required"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(tt.schema)
+			if err != nil {
+				t.Fatalf("invalid schema: %s", err)
+			}
+			actual := s.Validate(json.RawMessage(tt.value))
+			assertViolations(t, actual, tt.expected)
+		})
+	}
+}
+
+func Test_Validate_Message(t *testing.T) {
+	s := Must(`{"properties": {"name": {"type": "string"}}}`)
+	actual := s.Validate(map[string]any{"name": 9})
+	assertViolations(t, actual, []violation{{"/name", "#/properties/name/type"}})
+	if s := actual[0].String(); s != "/name: expected string, got integer (#/properties/name/type)" {
+		t.Errorf("wrong message: %s", s)
+	}
+}
+
+func Test_Validate_GoValues(t *testing.T) {
+	type User struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	s := Must(map[string]any{
+		"type":     "object",
+		"required": []string{"id", "name"},
+		"properties": map[string]any{
+			"id":   map[string]any{"type": "integer", "minimum": 1},
+			"name": map[string]any{"type": "string", "minLength": 1},
+		},
+	})
+	assertViolations(t, s.Validate(User{Id: 1, Name: "leto"}), nil)
+	assertViolations(t, s.Validate(User{}), []violation{{"/id", "#/properties/id/minimum"}, {"/name", "#/properties/name/minLength"}})
+	assertViolations(t, s.Validate(json.RawMessage(`{`)), []violation{{"", "#"}})
+}
+
+func Test_Ref(t *testing.T) {
+	s := Must(`{"components": {"schemas": {
+		"User": {"properties": {"id": {"$ref": "#/components/schemas/Id"}}},
+		"Id": {"type": "integer"}
+	}}}`)
+
+	user, err := s.Ref("#/components/schemas/User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertViolations(t, user.Validate(map[string]any{"id": 1}), nil)
+	assertViolations(t, user.Validate(map[string]any{"id": "1"}), []violation{{"/id", "#/components/schemas/Id/type"}})
+
+	if _, err := s.Ref("#/components/schemas/Nope"); err == nil {
+		t.Error("expected an error for an unresolvable ref")
+	}
+}
+
+func Test_New_Invalid(t *testing.T) {
+	for _, schema := range []any{`{`, `[1]`, 9} {
+		if _, err := New(schema); err == nil {
+			t.Errorf("expected an error for %v", schema)
+		}
+	}
+}
+
+func Test_RegisterFormat(t *testing.T) {
+	RegisterFormat("test-upper", func(value string) bool {
+		return strings.ToUpper(value) == value
+	})
+	s := Must(`{"format": "test-upper"}`)
+	assertViolations(t, s.Validate("ABC"), nil)
+	assertViolations(t, s.Validate("abc"), []violation{{"", "#/format"}})
+}
+
+func assertViolations(t *testing.T, actual []Violation, expected []violation) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d violations, got %d: %v", len(expected), len(actual), actual)
+	}
+	for i, e := range expected {
+		a := actual[i]
+		if a.Path != e.path || a.SchemaPath != e.schemaPath {
+			t.Errorf("violation %d: expected %q (%s), got %q (%s): %s", i, e.path, e.schemaPath, a.Path, a.SchemaPath, a.Message)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package sqlite
+
+/*
+A ready-made SQLite database for tests. It satisfies both
+tests.TestableDB and factory.SQLStorage (as well as
+factory.SQLiteProvider), so a project can do:
+
+	db := sqlite.Memory(schema)
+	factory.DB = db
+
+and get working factories and tests.Row/tests.Rows helpers
+*/
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"src.goblgobl.com/sqlite"
+	usqlite "src.goblgobl.com/utils/sqlite"
+	"src.goblgobl.com/utils/typed"
+)
+
+type DB struct {
+	path string
+	// a sqlite connection isn't safe for concurrent use and
+	// tests are often parallel
+	lock *sync.Mutex
+	conn usqlite.Conn
+}
+
+// An in-memory database. Any given sql (e.g. a schema) is
+// executed on the new connection. The caller is responsible
+// for closing it (see TempMemory).
+func Memory(setup ...string) DB {
+	return open(":memory:", setup...)
+}
+
+// Like Memory, but closed when the test (and all its subtests)
+// complete
+func TempMemory(t *testing.T, setup ...string) DB {
+	t.Helper()
+	db := Memory(setup...)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// A database stored in a temporary file, which is removed when
+// the test (and all its subtests) complete. Unlike Memory, the
+// file can be opened by other connections (e.g. by the code
+// being tested).
+func TempFile(t *testing.T, setup ...string) DB {
+	t.Helper()
+	db := open(filepath.Join(t.TempDir(), "test.sqlite"), setup...)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func open(path string, setup ...string) DB {
+	conn, err := usqlite.New(path, true)
+	if err != nil {
+		panic(err)
+	}
+	db := DB{
+		path: path,
+		conn: conn,
+		lock: new(sync.Mutex),
+	}
+	for _, sql := range setup {
+		db.MustExec(sql)
+	}
+	return db
+}
+
+// The path of the database file, or ":memory:"
+func (db DB) Path() string {
+	return db.path
+}
+
+func (db DB) Close() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.conn.Close()
+}
+
+// Implements factory.SQLiteProvider. The DB is locked while fn
+// runs, so fn must only use the conn it's given: calling any of
+// the DB's other methods (or tests.Row) from within fn deadlocks.
+func (db DB) WithDB(fn func(conn usqlite.Conn) error) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return fn(db.conn)
+}
+
+func (db DB) MustExec(sql string, args ...any) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.conn.MustExec(sql, args...)
+}
+
+func (db DB) RowToMap(sql string, args ...any) (typed.Typed, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.conn.RowToMap(sql, args...)
+}
+
+func (db DB) RowsToMap(sql string, args ...any) ([]typed.Typed, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.conn.RowsToMap(sql, args...)
+}
+
+func (db DB) Placeholder(i int) string {
+	return "?" + strconv.Itoa(i+1)
+}
+
+func (db DB) IsNotFound(err error) bool {
+	return errors.Is(err, sqlite.ErrNoRows)
+}
+
+// SQLite has no json type, json columns are stored as text/blob
+func (db DB) JSON(input any) (any, error) {
+	return json.Marshal(input)
+}
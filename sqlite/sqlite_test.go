@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"src.goblgobl.com/tests"
+	"src.goblgobl.com/tests/assert"
+	usqlite "src.goblgobl.com/utils/sqlite"
+)
+
+const schema = "create table users (id integer primary key, name text not null)"
+
+func Test_Memory(t *testing.T) {
+	db := Memory(schema)
+	defer db.Close()
+
+	assert.Equal(t, db.Path(), ":memory:")
+	db.MustExec("insert into users (id, name) values (?1, ?2)", 1, "leto")
+
+	row := tests.Row(db, "select * from users where id = $1", 1)
+	assert.Equal(t, row.Int("id"), 1)
+	assert.Equal(t, row.String("name"), "leto")
+
+	// each Memory is its own database
+	other := Memory(schema)
+	defer other.Close()
+	assert.Equal(t, len(tests.Rows(other, "select * from users")), 0)
+}
+
+func Test_TempMemory(t *testing.T) {
+	db := TempMemory(t, schema)
+	db.MustExec("insert into users (id, name) values (1, 'leto')")
+	assert.Equal(t, tests.Row(db, "select name from users").String("name"), "leto")
+}
+
+func Test_TempFile(t *testing.T) {
+	db := TempFile(t, schema)
+	assert.StringContains(t, db.Path(), "test.sqlite")
+	db.MustExec("insert into users (id, name) values (1, 'leto')")
+
+	// the file can be opened by another connection
+	other := open(db.Path())
+	defer other.Close()
+	assert.Equal(t, tests.Row(other, "select name from users where id = $1", 1).String("name"), "leto")
+}
+
+func Test_IsNotFound(t *testing.T) {
+	db := TempMemory(t, schema)
+
+	_, err := db.RowToMap("select * from users where id = ?1", 9)
+	assert.True(t, db.IsNotFound(err))
+	assert.False(t, db.IsNotFound(errors.New("other")))
+	assert.False(t, db.IsNotFound(nil))
+
+	// which tests.Row turns into nil
+	assert.Nil(t, tests.Row(db, "select * from users where id = $1", 9))
+}
+
+func Test_Placeholders(t *testing.T) {
+	db := TempMemory(t, schema)
+	assert.Equal(t, db.Placeholder(0), "?1")
+	assert.Equal(t, db.Placeholder(9), "?10")
+
+	db.MustExec("insert into users (id, name) values (1, 'leto'), (2, 'paul'), (3, 'jessica')")
+
+	// tests.Row and tests.Rows rewrite $N to ?N, keeping the order
+	row := tests.Row(db, "select * from users where name = $2 and id = $1", 2, "paul")
+	assert.Equal(t, row.Int("id"), 2)
+
+	rows := tests.Rows(db, "select id from users where id >= $1 and id <= $2 order by id", 2, 3)
+	assert.Equal(t, len(rows), 2)
+	assert.Equal(t, rows[0].Int("id"), 2)
+	assert.Equal(t, rows[1].Int("id"), 3)
+}
+
+func Test_WithDB(t *testing.T) {
+	db := TempMemory(t, schema)
+	err := db.WithDB(func(conn usqlite.Conn) error {
+		conn.MustExec("insert into users (id, name) values (1, 'leto')")
+		row, err := conn.RowToMap("select name from users where id = 1")
+		assert.Nil(t, err)
+		assert.Equal(t, row.String("name"), "leto")
+		return nil
+	})
+	assert.Nil(t, err)
+
+	expected := errors.New("rollback")
+	assert.Error(t, db.WithDB(func(conn usqlite.Conn) error { return expected }), expected)
+}
+
+func Test_Concurrent(t *testing.T) {
+	db := TempMemory(t, schema)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db.MustExec("insert into users (id, name) values (?1, ?2)", i, "user"+strconv.Itoa(i))
+			tests.Row(db, "select * from users where id = $1", i)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, tests.Row(db, "select count(*) as n from users").Int("n"), 20)
+}
+
+func Test_JSON(t *testing.T) {
+	db := TempMemory(t)
+	value, err := db.JSON(map[string]any{"a": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, string(value.([]byte)), `{"a":1}`)
+}
@@ -4,8 +4,10 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"testing"
+	"time"
 
-	"src.goblgobl.com/utils/log"
+	"src.goblgobl.com/tests/assert"
 	"src.goblgobl.com/utils/typed"
 )
 
@@ -45,6 +47,21 @@ func Rows(db TestableDB, sql string, args ...any) []typed.Typed {
 	return rows
 }
 
+// Polls (with Row) until the query returns a row and returns it.
+// Fails the test, reporting the query, if it doesn't within timeout.
+func EventuallyRow(t *testing.T, db TestableDB, timeout time.Duration, sql string, args ...any) typed.Typed {
+	t.Helper()
+	var row typed.Typed
+	found := assert.Poll(timeout, assert.PollInterval, func() bool {
+		row = Row(db, sql, args...)
+		return row != nil
+	})
+	if !found {
+		assert.Fail(t, "\nno row within %s for:\n  %s\n  args: %v", timeout, sql, args)
+	}
+	return row
+}
+
 func PG(dbName string) string {
 	pg := os.Getenv("GOBL_TEST_PG")
 	if pg == "" {
@@ -74,14 +91,3 @@ func StorageType() string {
 		panic("Unknown GOBL_TEST_STORAGE value. Should be one of: pg, cr, sqlite (default)")
 	}
 }
-
-func CaptureLog(fn func()) string {
-	defer func() {
-		log.Out = os.Stderr
-	}()
-
-	str := &strings.Builder{}
-	log.Out = str
-	fn()
-	return str.String()
-}
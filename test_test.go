@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"src.goblgobl.com/tests/assert"
+	"src.goblgobl.com/utils/typed"
+)
+
+var errNotFound = errors.New("not found")
+
+// returns a row once it has been queried `after` times
+type slowDB struct {
+	calls int
+	after int
+}
+
+func (db *slowDB) Placeholder(i int) string  { return "$1" }
+func (db *slowDB) IsNotFound(err error) bool { return err == errNotFound }
+func (db *slowDB) RowsToMap(sql string, args ...any) ([]typed.Typed, error) {
+	return nil, nil
+}
+
+func (db *slowDB) RowToMap(sql string, args ...any) (typed.Typed, error) {
+	db.calls++
+	if db.calls < db.after {
+		return nil, errNotFound
+	}
+	return typed.Typed{"id": 1}, nil
+}
+
+func Test_EventuallyRow(t *testing.T) {
+	db := &slowDB{after: 3}
+	row := EventuallyRow(t, db, time.Second, "select id from users where id = $1", 1)
+	assert.Equal(t, row.Int("id"), 1)
+	assert.Equal(t, db.calls, 3)
+}